@@ -0,0 +1,292 @@
+// fancache 是fancache缓存目录的维护工具
+//
+//	fancache fsck [-repair] <dir>   检查缓存目录，-repair时删除有问题的文件
+//	fancache stats <dir>            统计缓存项数量、占用空间以及问题数量
+//	fancache ls <dir>               列出所有有效的缓存项
+//	fancache get <dir> <key>        输出缓存项的值（仅支持基础类型）
+//	fancache rm <dir> <key>...      删除缓存项
+//	fancache gc <dir>               清理过期、损坏的缓存项以及残留的临时文件
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/821869798/fankit/fancache"
+)
+
+const usageText = `usage: fancache <command> [arguments]
+
+commands:
+  fsck [-repair] <dir>   check a cache directory, remove broken files with -repair
+  stats <dir>            show item count, size and problem counts
+  ls <dir>               list live cache items
+  get <dir> <key>        print the value of a cache item (basic types only)
+  rm <dir> <key>...      remove cache items
+  gc <dir>               remove expired, corrupted and leftover temp files
+`
+
+// errUsage 命令行参数错误，main会输出用法并以状态码2退出
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(usageText)
+		return
+	}
+	if errors.Is(err, errUsage) {
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "fancache:", err)
+		}
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fancache:", err)
+		os.Exit(1)
+	}
+}
+
+// run 执行args描述的子命令，结果输出到stdout
+func run(args []string, stdout io.Writer) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "fsck":
+		return runFsck(args, stdout)
+	case "stats":
+		return runStats(args, stdout)
+	case "ls":
+		return runLs(args, stdout)
+	case "get":
+		return runGet(args, stdout)
+	case "rm":
+		return runRm(args)
+	case "gc":
+		return runGc(args, stdout)
+	case "help", "-h", "-help", "--help":
+		_, err := fmt.Fprint(stdout, usageText)
+		return err
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+}
+
+// parseArgs 解析子命令参数，要求至少minArgs个位置参数
+func parseArgs(name string, args []string, minArgs int, setup func(fs *flag.FlagSet)) ([]string, error) {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	if setup != nil {
+		setup(flagSet)
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if flagSet.NArg() < minArgs {
+		return nil, fmt.Errorf("%w: %s needs at least %d arguments", errUsage, name, minArgs)
+	}
+	return flagSet.Args(), nil
+}
+
+func runFsck(args []string, stdout io.Writer) error {
+	var repair bool
+	args, err := parseArgs("fsck", args, 1, func(fs *flag.FlagSet) {
+		fs.BoolVar(&repair, "repair", false, "remove broken files")
+	})
+	if err != nil {
+		return err
+	}
+
+	report, err := fancache.Verify(args[0], fancache.VerifyOptions{Repair: repair})
+	if err != nil {
+		return err
+	}
+
+	unrepaired := 0
+	for _, issue := range report.Issues {
+		status := "found"
+		if issue.Repaired {
+			status = "removed"
+		} else {
+			unrepaired++
+		}
+		line := fmt.Sprintf("%-16s %-8s %s", issue.Kind, status, issue.Path)
+		if issue.Key != "" {
+			line += fmt.Sprintf(" key=%q", issue.Key)
+		}
+		if issue.Err != nil {
+			line += fmt.Sprintf(" (%v)", issue.Err)
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	fmt.Fprintf(stdout, "%d items ok, %d problems, %d repaired\n", len(report.Entries), len(report.Issues), len(report.Issues)-unrepaired)
+
+	if unrepaired > 0 {
+		return fmt.Errorf("%d problems found, run with -repair to fix them", unrepaired)
+	}
+	return nil
+}
+
+func runStats(args []string, stdout io.Writer) error {
+	args, err := parseArgs("stats", args, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	report, err := fancache.Verify(args[0], fancache.VerifyOptions{})
+	if err != nil {
+		return err
+	}
+
+	negative := 0
+	for _, entry := range report.Entries {
+		if entry.NotFound {
+			negative++
+		}
+	}
+	fmt.Fprintf(stdout, "items:   %d (%d not found)\n", len(report.Entries), negative)
+	fmt.Fprintf(stdout, "bytes:   %d\n", report.Bytes())
+	if len(report.Entries) > 0 {
+		earliest, latest := report.Entries[0].Expiration, report.Entries[0].Expiration
+		for _, entry := range report.Entries[1:] {
+			if entry.Expiration.Before(earliest) {
+				earliest = entry.Expiration
+			}
+			if entry.Expiration.After(latest) {
+				latest = entry.Expiration
+			}
+		}
+		fmt.Fprintf(stdout, "expires: %s .. %s\n", earliest.Format(time.RFC3339), latest.Format(time.RFC3339))
+	}
+	kinds := []fancache.IssueKind{
+		fancache.IssueExpired,
+		fancache.IssueOrphanedTemp,
+		fancache.IssueCorrupted,
+		fancache.IssueHashMismatch,
+		fancache.IssueVersionMismatch,
+	}
+	for _, kind := range kinds {
+		fmt.Fprintf(stdout, "%-18s%d\n", kind.String()+":", report.Count(kind))
+	}
+	return nil
+}
+
+func runLs(args []string, stdout io.Writer) error {
+	args, err := parseArgs("ls", args, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	report, err := fancache.Verify(args[0], fancache.VerifyOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range report.Entries {
+		line := fmt.Sprintf("%s  %10d  %q", entry.Expiration.Format(time.RFC3339), entry.Size, entry.Key)
+		if entry.NotFound {
+			line += "  (not found)"
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	return nil
+}
+
+// runGet 只读取缓存文件，不打开缓存，避免读取过期或损坏的缓存项时把它们删除
+func runGet(args []string, stdout io.Writer) error {
+	args, err := parseArgs("get", args, 2, nil)
+	if err != nil {
+		return err
+	}
+	dir, key := args[0], args[1]
+
+	// gob不能把具体类型解码到interface{}，因此依次尝试常见的基础类型
+	candidates := []interface{}{new(string), new([]byte), new(int64), new(uint64), new(float64), new(bool)}
+	var lastErr error
+	for _, value := range candidates {
+		entry, err := fancache.Peek(dir, key, value)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("key %q not found", key)
+		}
+		if entry.Key != "" && time.Now().After(entry.Expiration) {
+			return fmt.Errorf("key %q expired at %s", key, entry.Expiration.Format(time.RFC3339))
+		}
+		if errors.Is(err, fancache.ErrCachedNotFound) {
+			return fmt.Errorf("key %q is cached as not found", key)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		switch v := value.(type) {
+		case *[]byte:
+			_, err = stdout.Write(*v)
+			return err
+		case *string:
+			_, err = fmt.Fprintln(stdout, *v)
+		case *int64:
+			_, err = fmt.Fprintln(stdout, *v)
+		case *uint64:
+			_, err = fmt.Fprintln(stdout, *v)
+		case *float64:
+			_, err = fmt.Fprintln(stdout, *v)
+		case *bool:
+			_, err = fmt.Fprintln(stdout, *v)
+		}
+		return err
+	}
+	return fmt.Errorf("cannot decode value of key %q: %w", key, lastErr)
+}
+
+func runRm(args []string) error {
+	args, err := parseArgs("rm", args, 2, nil)
+	if err != nil {
+		return err
+	}
+
+	fc, err := fancache.NewFileCache(args[0], fancache.WithMaxItems(0), fancache.WithKeepInvalidFiles())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range args[1:] {
+		if err := fc.Remove(key); err != nil {
+			errs = append(errs, fmt.Errorf("remove %q: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runGc(args []string, stdout io.Writer) error {
+	args, err := parseArgs("gc", args, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	report, err := fancache.Verify(args[0], fancache.VerifyOptions{Repair: true})
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, issue := range report.Issues {
+		if issue.Repaired {
+			removed++
+		}
+	}
+	fmt.Fprintf(stdout, "removed %d files, %d items left\n", removed, len(report.Entries))
+	if removed != len(report.Issues) {
+		return fmt.Errorf("failed to remove %d files", len(report.Issues)-removed)
+	}
+	return nil
+}
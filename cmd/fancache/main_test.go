@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/821869798/fankit/fancache"
+)
+
+// newTestCache creates a cache directory with a live, an expired, a negative
+// item and a leftover temp file.
+func newTestCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	fc, err := fancache.NewFileCache(dir, fancache.WithMaxItems(0))
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	if err := fc.Set("live", "hello", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("expired", "stale", -time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.SetNotFound("negative", time.Hour); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "leftover.tmp"), []byte("partial"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return dir
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	return len(entries)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string // "<dir>" is replaced with the cache directory
+		wantErr   string   // empty means success
+		wantOut   []string
+		wantFiles int // files left in the cache directory
+	}{
+		{name: "fsck", args: []string{"fsck", "<dir>"}, wantErr: "2 problems", wantOut: []string{"expired", "orphaned-temp", "2 items ok"}, wantFiles: 4},
+		{name: "fsck repair", args: []string{"fsck", "-repair", "<dir>"}, wantOut: []string{"removed", "2 repaired"}, wantFiles: 2},
+		{name: "ls", args: []string{"ls", "<dir>"}, wantOut: []string{`"live"`, `"negative"  (not found)`}, wantFiles: 4},
+		{name: "get", args: []string{"get", "<dir>", "live"}, wantOut: []string{"hello\n"}, wantFiles: 4},
+		{name: "get expired", args: []string{"get", "<dir>", "expired"}, wantErr: `key "expired" expired`, wantFiles: 4},
+		{name: "get negative", args: []string{"get", "<dir>", "negative"}, wantErr: "cached as not found", wantFiles: 4},
+		{name: "get missing", args: []string{"get", "<dir>", "missing"}, wantErr: `key "missing" not found`, wantFiles: 4},
+		{name: "rm", args: []string{"rm", "<dir>", "live", "negative"}, wantFiles: 2},
+		{name: "get without key", args: []string{"get", "<dir>"}, wantErr: "invalid usage", wantFiles: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestCache(t)
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				if arg == "<dir>" {
+					arg = dir
+				}
+				args[i] = arg
+			}
+
+			var out bytes.Buffer
+			err := run(args, &out)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("run(%v) failed: %v", tt.args, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("run(%v) error = %v; want %q", tt.args, err, tt.wantErr)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("run(%v) output = %q; want it to contain %q", tt.args, out.String(), want)
+				}
+			}
+			if got := countFiles(t, dir); got != tt.wantFiles {
+				t.Errorf("files after run(%v) = %d; want %d", tt.args, got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"bogus"}, {"ls"}, {"fsck", "-bogus", "dir"}} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("run(%v) error = %v; want errUsage", args, err)
+		}
+	}
+}
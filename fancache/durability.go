@@ -0,0 +1,69 @@
+package fancache
+
+import (
+	"fmt"
+
+	"github.com/821869798/fankit/fanpath"
+)
+
+// Durability 写入的持久化级别，决定Set在返回前对哪些数据调用fsync
+// 各级别在系统崩溃或断电时的表现如下，单纯的进程崩溃不受级别影响：已返回的Set都不会丢失
+type Durability int
+
+const (
+	// DurabilityNone 不调用fsync，写入最快，适合可随时丢弃的缓存
+	// 系统崩溃后最近写入的项可能丢失，也可能留下内容不完整的文件，重新打开缓存时会被当作损坏文件清理
+	DurabilityNone Durability = iota
+
+	// DurabilityFile 重命名前对临时文件调用fsync（默认）
+	// 系统崩溃后每个缓存文件要么是完整的旧值要么是完整的新值，不会读到不完整的数据；
+	// 但重命名和删除本身可能尚未落盘，最近的Set可能回退到旧值或者丢失，最近的Remove可能失效
+	DurabilityFile
+
+	// DurabilityFileAndDir 在DurabilityFile的基础上，重命名以及Remove删除文件后再对缓存目录调用fsync
+	// Set和Remove返回后，结果在系统崩溃后也能保留；淘汰和过期清理不做目录同步，崩溃后这些项可能重新出现，
+	// 过期的项会在重新打开时被清理
+	DurabilityFileAndDir
+)
+
+func (d Durability) String() string {
+	switch d {
+	case DurabilityNone:
+		return "none"
+	case DurabilityFile:
+		return "file"
+	case DurabilityFileAndDir:
+		return "file+dir"
+	default:
+		return fmt.Sprintf("Durability(%d)", int(d))
+	}
+}
+
+// syncDirIfNeeded 按持久化级别同步缓存目录，使重命名和删除落盘
+func (fc *FileCache) syncDirIfNeeded() error {
+	if fc.durability < DurabilityFileAndDir {
+		return nil
+	}
+	if err := fanpath.FsyncDir(fc.dir); err != nil {
+		return fmt.Errorf("failed to sync cache directory: %w", err)
+	}
+	fc.crashPoint(stageDirSynced)
+	return nil
+}
+
+// writeStage 写入流程中可以注入崩溃的阶段
+type writeStage int
+
+const (
+	stageWritten    writeStage = iota // 临时文件写入完成，尚未fsync
+	stageFileSynced                   // 临时文件已fsync，尚未重命名
+	stageRenamed                      // 已重命名为正式文件，目录尚未fsync
+	stageDirSynced                    // 目录已fsync
+)
+
+// crashPoint 调用实例上测试用的崩溃注入点，测试在crashHook中panic来模拟进程在该阶段崩溃
+func (fc *FileCache) crashPoint(stage writeStage) {
+	if fc.crashHook != nil {
+		fc.crashHook(stage)
+	}
+}
@@ -0,0 +1,164 @@
+package fancache
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// durabilityStages lists the write stages a Set and a Remove pass through at
+// each durability level.
+var durabilityStages = []struct {
+	durability Durability
+	set        []writeStage
+	remove     []writeStage
+}{
+	{DurabilityNone, []writeStage{stageWritten, stageRenamed}, nil},
+	{DurabilityFile, []writeStage{stageWritten, stageFileSynced, stageRenamed}, nil},
+	{DurabilityFileAndDir, []writeStage{stageWritten, stageFileSynced, stageRenamed, stageDirSynced}, []writeStage{stageDirSynced}},
+}
+
+func TestFileCache_DurabilityStages(t *testing.T) {
+	for _, test := range durabilityStages {
+		fc, cleanup := setupTestCache(t, WithDurability(test.durability))
+
+		var stages []writeStage
+		fc.crashHook = func(stage writeStage) { stages = append(stages, stage) }
+
+		if err := fc.Set("key", "value", time.Minute); err != nil {
+			t.Fatalf("%s: Set failed: %v", test.durability, err)
+		}
+		if !reflect.DeepEqual(stages, test.set) {
+			t.Errorf("%s: Set stages = %v; want %v", test.durability, stages, test.set)
+		}
+
+		stages = nil
+		if err := fc.Remove("key"); err != nil {
+			t.Fatalf("%s: Remove failed: %v", test.durability, err)
+		}
+		if !reflect.DeepEqual(stages, test.remove) {
+			t.Errorf("%s: Remove stages = %v; want %v", test.durability, stages, test.remove)
+		}
+
+		cleanup()
+	}
+}
+
+type simulatedCrash struct{ stage writeStage }
+
+// TestFileCache_CrashRecovery kills a Set at each stage of the write path of
+// every durability level, then simulates an OS crash on the cache directory
+// and reopens it, checking which value survives. The OS crash loses whatever
+// was not synced yet, taking the worst case a filesystem allows:
+//   - without the file fsync the rename may reach the disk before the data,
+//     leaving a truncated cache file (the entry is lost as corrupt);
+//   - without the directory fsync the rename itself is lost (the old value
+//     comes back).
+//
+// So at the last stage of each level None loses the entry, File rolls back
+// to the old value and FileAndDir keeps the new one.
+func TestFileCache_CrashRecovery(t *testing.T) {
+	for _, level := range durabilityStages {
+		for _, crashAt := range level.set {
+			reached := func(stage writeStage) bool {
+				if stage > crashAt {
+					return false
+				}
+				for _, s := range level.set {
+					if s == stage {
+						return true
+					}
+				}
+				return false
+			}
+
+			want := "old"
+			switch {
+			case !reached(stageRenamed):
+			case !reached(stageFileSynced):
+				want = "" // lost
+			case reached(stageDirSynced):
+				want = "new"
+			}
+			testCrashRecovery(t, level.durability, crashAt, reached, want)
+		}
+	}
+}
+
+// testCrashRecovery crashes a Set of "new" over "old" at crashAt, applies the
+// OS crash model described on TestFileCache_CrashRecovery and checks that a
+// reopened cache returns want ("" meaning the entry is gone).
+func testCrashRecovery(t *testing.T, durability Durability, crashAt writeStage, reached func(writeStage) bool, want string) {
+	t.Helper()
+	fc, cleanup := setupTestCache(t, WithDurability(durability))
+	defer cleanup()
+	if err := fc.Set("key", "old", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	filePath := fc.filePath("key")
+	oldData, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	fc.crashHook = func(stage writeStage) {
+		if stage == crashAt {
+			panic(simulatedCrash{stage})
+		}
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(simulatedCrash); !ok {
+					panic(r)
+				}
+			}
+		}()
+		fc.Set("key", "new", time.Minute)
+		t.Errorf("%s stage %d: Set did not crash", durability, crashAt)
+	}()
+
+	// Lose what the OS had not written back yet.
+	tempPath := filePath + ".tmp"
+	switch {
+	case !reached(stageRenamed):
+		if !reached(stageFileSynced) {
+			if err := os.Truncate(tempPath, 0); err != nil {
+				t.Fatalf("%s stage %d: truncate temp file: %v", durability, crashAt, err)
+			}
+		}
+	case !reached(stageFileSynced):
+		if err := os.Truncate(filePath, 0); err != nil {
+			t.Fatalf("%s stage %d: truncate cache file: %v", durability, crashAt, err)
+		}
+	case !reached(stageDirSynced):
+		if err := os.WriteFile(filePath, oldData, 0644); err != nil {
+			t.Fatalf("%s stage %d: undo rename: %v", durability, crashAt, err)
+		}
+	}
+
+	// The crashed instance is abandoned; a fresh one recovers from disk.
+	recovered, err := NewFileCache(fc.dir)
+	if err != nil {
+		t.Fatalf("%s stage %d: NewFileCache failed: %v", durability, crashAt, err)
+	}
+	var got string
+	found, err := recovered.Get("key", &got)
+	if err != nil {
+		t.Fatalf("%s stage %d: Get after crash failed: %v", durability, crashAt, err)
+	}
+	if !found {
+		got = ""
+	}
+	if got != want {
+		t.Errorf("%s stage %d: value after crash = %q; want %q", durability, crashAt, got, want)
+	}
+
+	// A crash before the rename leaves the temp file behind; reopening the
+	// cache must clean it up.
+	if _, err := os.Stat(tempPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s stage %d: temp file left after recovery: %v", durability, crashAt, err)
+	}
+}
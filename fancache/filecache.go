@@ -1,29 +1,43 @@
 package fancache
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/md5"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/821869798/fankit/fanpath"
 )
 
 const (
-	CurrentVersion       = 1
+	// CurrentVersion 缓存文件格式的版本，格式变化时递增；扫描缓存目录时无法读取的版本被当作无效文件清理
+	// 1: 头部和数据使用同一个gob编码器
+	// 2: 头部和数据使用各自独立的gob编码器
+	// 3: 头部增加NotFound字段
+	CurrentVersion = 3
+	// minReadableVersion 仍能读取的最低版本，版本2的头部没有NotFound字段，解码后为false，其余格式与版本3相同
+	minReadableVersion = 2
+
 	DefaultMaxItems      = 500
 	DefaultEvictPercent  = 0.3  // 淘汰30%的项目
 	RandomEvictThreshold = 1000 // 当缓存项超过1000时，使用随机淘汰策略
+	keyLockStripes       = 64   // 按键分段的文件锁数量
 )
 
 var (
 	ErrCacheCorrupted = errors.New("cache file corrupted")
 	ErrKeyMismatch    = errors.New("cache key mismatch")
+	ErrCachedNotFound = errors.New("cached not found") // 命中了SetNotFound写入的负缓存
 )
 
 // CacheHeader 缓存头部结构
@@ -31,6 +45,12 @@ type CacheHeader struct {
 	Version    byte   `gob:"v"`
 	Expiration int64  `gob:"e"`
 	Key        string `gob:"k"`
+	NotFound   bool   `gob:"n"` // 负缓存项，文件中只有头部没有数据，版本3起支持
+}
+
+// readableVersion 是否能读取该版本的缓存文件或快照
+func readableVersion(version int) bool {
+	return version >= minReadableVersion && version <= CurrentVersion
 }
 
 // FileCache 文件缓存结构
@@ -38,8 +58,16 @@ type FileCache struct {
 	dir          string
 	maxItems     int
 	evictPercent float64
-	mu           sync.RWMutex
-	keys         map[string]CacheHeader // key是原始键
+	keepInvalid  bool                       // 扫描时保留损坏和过期的文件，交给Verify处理
+	durability   Durability                 // 写入的持久化级别
+	mu           sync.RWMutex               // 只保护keys索引，不在持有期间做文件I/O
+	keys         map[string]CacheHeader     // key是原始键
+	keyLocks     [keyLockStripes]sync.Mutex // 按键分段的锁，串行化同一个键的文件写入和删除
+	watchBuffer  int                        // 每个订阅者的事件缓冲区大小
+	watchMu      sync.Mutex
+	watchers     map[*watcher]struct{}
+	stats        cacheStats
+	crashHook    func(stage writeStage) // 测试用的崩溃注入点，见crashPoint
 }
 
 // NewFileCache 创建新的文件缓存实例
@@ -52,6 +80,8 @@ func NewFileCache(cacheDir string, options ...Option) (*FileCache, error) {
 		dir:          cacheDir,
 		maxItems:     DefaultMaxItems,
 		evictPercent: DefaultEvictPercent,
+		durability:   DurabilityFile,
+		watchBuffer:  DefaultWatchBuffer,
 		keys:         make(map[string]CacheHeader),
 	}
 
@@ -67,6 +97,16 @@ func NewFileCache(cacheDir string, options ...Option) (*FileCache, error) {
 	return fc, nil
 }
 
+// DefaultCacheDir 返回appName在当前用户缓存目录下的位置，可作为NewFileCache的cacheDir
+// 位置由fanpath.AppDirs决定，例如linux上为~/.cache/<appName>，可以用<APPNAME>_CACHE_DIR等环境变量覆盖
+func DefaultCacheDir(appName string) (string, error) {
+	dirs, err := fanpath.AppDirs(appName)
+	if err != nil {
+		return "", err
+	}
+	return dirs.Cache, nil
+}
+
 // Option 配置选项
 type Option func(*FileCache)
 
@@ -84,6 +124,21 @@ func WithEvictPercent(percent float64) Option {
 	}
 }
 
+// WithDurability 设置写入的持久化级别，默认为DurabilityFile
+func WithDurability(durability Durability) Option {
+	return func(fc *FileCache) {
+		fc.durability = durability
+	}
+}
+
+// WithKeepInvalidFiles 扫描缓存目录时只忽略而不删除损坏、过期的文件以及残留的临时文件，
+// 便于之后用Verify检查；这些文件之后仍可能在Set同一个键时被覆盖
+func WithKeepInvalidFiles() Option {
+	return func(fc *FileCache) {
+		fc.keepInvalid = true
+	}
+}
+
 // scanCacheDir 扫描缓存目录，初始化keys
 func (fc *FileCache) scanCacheDir() error {
 	entries, err := os.ReadDir(fc.dir)
@@ -100,44 +155,27 @@ func (fc *FileCache) scanCacheDir() error {
 			continue
 		}
 
-		hashedKeyAsFileName := entry.Name()
-		filePath := filepath.Join(fc.dir, hashedKeyAsFileName)
-
-		header, err := fc.readCacheHeader(filePath)
-		if err != nil {
+		filePath := filepath.Join(fc.dir, entry.Name())
+		// 启动时只读取头部，数据损坏的文件由Get在读取时发现并清理，完整检查交给Verify
+		header, issue, _ := inspectCacheFile(filePath, now, false)
+		if issue != IssueNone {
 			corruptedFiles = append(corruptedFiles, filePath)
 			continue
 		}
-
-		// 验证原始键的完整性
-		if header.Key == "" {
-			corruptedFiles = append(corruptedFiles, filePath)
-			continue
-		}
-
-		// 验证哈希一致性（防止文件名被篡改）
-		expectedHash := fc.getHash(header.Key)
-		if expectedHash != hashedKeyAsFileName {
-			corruptedFiles = append(corruptedFiles, filePath)
-			continue
-		}
-
-		if now > header.Expiration {
-			corruptedFiles = append(corruptedFiles, filePath)
-		} else {
-			tempKeys[header.Key] = header
-		}
+		tempKeys[header.Key] = header
 	}
 
 	// 清理损坏和过期的文件
-	fc.cleanupFiles(corruptedFiles)
+	if !fc.keepInvalid {
+		fc.cleanupFiles(corruptedFiles)
+	}
 	fc.keys = tempKeys
 
 	return nil
 }
 
 // readCacheHeader 读取缓存文件头部
-func (fc *FileCache) readCacheHeader(filePath string) (CacheHeader, error) {
+func readCacheHeader(filePath string) (CacheHeader, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return CacheHeader{}, err
@@ -166,61 +204,141 @@ func (fc *FileCache) Set(key string, value interface{}, duration time.Duration)
 		return errors.New("cache key cannot be empty")
 	}
 
-	fc.mu.Lock()
-	defer fc.mu.Unlock()
+	header := CacheHeader{
+		Version:    CurrentVersion,
+		Expiration: time.Now().Add(duration).UnixNano(),
+		Key:        key,
+	}
 
-	// 检查是否需要淘汰
-	_, keyExists := fc.keys[key]
-	if fc.maxItems > 0 && len(fc.keys) >= fc.maxItems && !keyExists {
-		if err := fc.evictCache(); err != nil { // 使用evictCache替换evictOldest
-			return fmt.Errorf("failed to evict items: %w", err)
-		}
+	if err := fc.store(header, func(filePath string) error {
+		return fc.writeToFile(filePath, header, value)
+	}); err != nil {
+		return err
+	}
+	fc.stats.sets.Add(1)
+	return nil
+}
+
+// SetNotFound 缓存"不存在"这一查询结果（负缓存），通常使用比正常缓存更短的有效期
+// 有效期内Get返回(false, ErrCachedNotFound)，以便与未缓存的情况区分；之后的Set会覆盖它
+func (fc *FileCache) SetNotFound(key string, duration time.Duration) error {
+	if key == "" {
+		return errors.New("cache key cannot be empty")
 	}
 
 	header := CacheHeader{
 		Version:    CurrentVersion,
 		Expiration: time.Now().Add(duration).UnixNano(),
 		Key:        key,
+		NotFound:   true,
 	}
 
-	hashedKey := fc.getHash(key)
-	filePath := filepath.Join(fc.dir, hashedKey)
+	if err := fc.store(header, func(filePath string) error {
+		return fc.writeRawToFile(filePath, header, nil)
+	}); err != nil {
+		return err
+	}
+	fc.stats.negativeSets.Add(1)
+	return nil
+}
 
-	// 使用临时文件写入，确保原子性
+// store 写入缓存项，文件I/O期间只持有该键的分段锁
+func (fc *FileCache) store(header CacheHeader, write func(filePath string) error) error {
+	lock := fc.keyLock(header.Key)
+	lock.Lock()
+	evicted, err := fc.storeLocked(header, write)
+	lock.Unlock()
+
+	// 被淘汰项的文件需要获取它们各自的分段锁才能删除，必须在释放当前键的锁之后进行
+	fc.dropEvicted(evicted)
+	return err
+}
+
+// storeLocked 原子性地写入缓存文件并更新索引，必要时淘汰其他项，返回被淘汰的键（内部使用）
+// 调用方必须持有该键的分段锁：文件写入、同步和重命名都不持有全局锁，只有更新索引时才持有
+// 淘汰和写入事件在更新索引时发出，所有事件都在持有全局锁修改索引的同时发出，因此事件顺序与索引的变化顺序一致
+func (fc *FileCache) storeLocked(header CacheHeader, write func(filePath string) error) ([]string, error) {
+	filePath := fc.filePath(header.Key)
+
+	// 使用临时文件写入，确保原子性；同一个键的写入由分段锁串行化，临时文件不会冲突
 	tempPath := filePath + ".tmp"
-	if err := fc.writeToFile(tempPath, header, value); err != nil {
+	if err := write(tempPath); err != nil {
 		os.Remove(tempPath)
-		return err
+		return nil, err
 	}
 
 	// 原子性重命名
 	if err := os.Rename(tempPath, filePath); err != nil {
 		_ = os.Remove(tempPath)
-		return fmt.Errorf("failed to rename temp file: %w", err)
+		return nil, fmt.Errorf("failed to rename temp file: %w", err)
 	}
+	fc.crashPoint(stageRenamed)
 
-	fc.keys[key] = header
-	return nil
+	if err := fc.syncDirIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	// 检查是否需要淘汰
+	var evicted []string
+	_, keyExists := fc.keys[header.Key]
+	if fc.maxItems > 0 && len(fc.keys) >= fc.maxItems && !keyExists {
+		evicted = fc.evictCache()
+	}
+
+	fc.keys[header.Key] = header
+	fc.notifyAll(EventEvict, evicted)
+	fc.notify(EventSet, header.Key)
+	return evicted, nil
 }
 
 // writeToFile 写入数据到文件
+// 头部和数据分别使用独立的gob编码器写入，使数据部分可以脱离头部单独搬运（见Export/Import）
 func (fc *FileCache) writeToFile(filePath string, header CacheHeader, value interface{}) error {
+	return fc.writeFile(filePath, header, func(file *os.File) error {
+		if err := gob.NewEncoder(file).Encode(value); err != nil {
+			return fmt.Errorf("failed to encode value: %w", err)
+		}
+		return nil
+	})
+}
+
+// writeRawToFile 写入头部以及已经编码好的数据到文件
+func (fc *FileCache) writeRawToFile(filePath string, header CacheHeader, payload []byte) error {
+	return fc.writeFile(filePath, header, func(file *os.File) error {
+		_, err := file.Write(payload)
+		return err
+	})
+}
+
+// writeFile 创建文件并写入头部，随后由writePayload写入数据
+func (fc *FileCache) writeFile(filePath string, header CacheHeader, writePayload func(file *os.File) error) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(header); err != nil {
+	if err := gob.NewEncoder(file).Encode(header); err != nil {
 		return fmt.Errorf("failed to encode header: %w", err)
 	}
-	if err := encoder.Encode(value); err != nil {
-		return fmt.Errorf("failed to encode value: %w", err)
+	if err := writePayload(file); err != nil {
+		return err
+	}
+	fc.crashPoint(stageWritten)
+
+	if fc.durability < DurabilityFile {
+		return nil
 	}
 
 	// 确保数据写入磁盘
-	return file.Sync()
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	fc.crashPoint(stageFileSynced)
+	return nil
 }
 
 // Get 获取缓存
@@ -234,72 +352,111 @@ func (fc *FileCache) Get(key string, value interface{}) (bool, error) {
 	fc.mu.RUnlock()
 
 	if !exists {
+		fc.stats.misses.Add(1)
 		return false, nil
 	}
 
 	now := time.Now().UnixNano()
 	if now > header.Expiration {
-		// 双重检查锁定模式处理过期
-		fc.mu.Lock()
-		// 再次检查key是否存在以及是否过期
-		if currentHeader, stillExists := fc.keys[key]; stillExists && now > currentHeader.Expiration {
-			fc.removeUnsafe(key)
+		fc.stats.misses.Add(1)
+		if fc.removeStale(header, EventExpire) {
+			fc.stats.expirations.Add(1)
 		}
-		fc.mu.Unlock()
 		return false, nil
 	}
 
+	if header.NotFound {
+		fc.stats.negativeHits.Add(1)
+		return false, ErrCachedNotFound
+	}
+
 	err := fc.readFromFile(key, value)
 	if err != nil {
+		if errors.Is(err, ErrCachedNotFound) { // 并发的SetNotFound已经替换了文件
+			fc.stats.negativeHits.Add(1)
+			return false, err
+		}
+		fc.stats.misses.Add(1)
 		// 如果文件不存在，或文件已损坏，都应清理
 		isCorrupted := errors.Is(err, ErrCacheCorrupted) || errors.Is(err, ErrKeyMismatch)
 		if errors.Is(err, fs.ErrNotExist) || isCorrupted {
-			fc.mu.Lock()
-			// 再次检查，防止在获取锁的过程中状态变化
-			if h, ok := fc.keys[key]; ok && h.Expiration == header.Expiration { // 确保是同一个缓存项
-				fc.removeUnsafe(key)
-			}
-			fc.mu.Unlock()
+			fc.removeStale(header, EventCorrupt)
 		}
 		return false, err // 返回interface{}的零值和错误
 	}
 
+	fc.stats.hits.Add(1)
 	return true, nil
 }
 
 // readFromFile 从文件读取数据
 func (fc *FileCache) readFromFile(key string, value interface{}) error {
-	hashedKey := fc.getHash(key)
-	filePath := filepath.Join(fc.dir, hashedKey)
+	return readCacheFile(fc.filePath(key), key, value)
+}
 
+// readCacheFile 读取缓存文件filePath中key的值，不修改任何文件
+func readCacheFile(filePath, key string, value interface{}) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	decoder := gob.NewDecoder(file)
+	// 头部和数据是两段独立的gob流，共用同一个带缓冲的reader，避免第一个解码器多读数据
+	reader := bufio.NewReader(file)
 
 	var fileHeader CacheHeader
-	if err := decoder.Decode(&fileHeader); err != nil {
-		return fmt.Errorf("corrupted header: %w", err)
+	if err := gob.NewDecoder(reader).Decode(&fileHeader); err != nil {
+		return fmt.Errorf("%w: corrupted header: %v", ErrCacheCorrupted, err)
 	}
 
 	// 验证头部一致性
 	if fileHeader.Key != key {
 		return ErrKeyMismatch
 	}
+	if fileHeader.NotFound {
+		return ErrCachedNotFound
+	}
 
 	// 解码数据到interface{}变量data的地址
-	if err := decoder.Decode(value); err != nil {
+	if err := gob.NewDecoder(reader).Decode(value); err != nil {
 		return fmt.Errorf("corrupted data: %w", err)
 	}
 
 	return nil
 }
 
+// readRawFromFile 读取缓存文件，返回头部以及头部之后尚未解码的数据
+func (fc *FileCache) readRawFromFile(key string) (CacheHeader, []byte, error) {
+	data, err := os.ReadFile(fc.filePath(key))
+	if err != nil {
+		return CacheHeader{}, nil, err
+	}
+
+	header, payload, err := splitCacheData(data)
+	if err != nil {
+		return CacheHeader{}, nil, err
+	}
+	if header.Key != key {
+		return CacheHeader{}, nil, ErrKeyMismatch
+	}
+	return header, payload, nil
+}
+
+// splitCacheData 把缓存文件内容拆分为头部和数据两部分
+func splitCacheData(data []byte) (CacheHeader, []byte, error) {
+	// bytes.Reader实现了io.ByteReader，gob不会额外包装缓冲，解码后的读取位置正好是头部的结尾
+	reader := bytes.NewReader(data)
+
+	var header CacheHeader
+	if err := gob.NewDecoder(reader).Decode(&header); err != nil {
+		return CacheHeader{}, nil, fmt.Errorf("%w: %v", ErrCacheCorrupted, err)
+	}
+	return header, data[len(data)-reader.Len():], nil
+}
+
 // evictRandom 随机淘汰缓存项，适用于大数量缓存
-func (fc *FileCache) evictRandom() error {
+func (fc *FileCache) evictRandom() []string {
 	numToEvict := int(float64(len(fc.keys)) * fc.evictPercent)
 	if numToEvict == 0 && len(fc.keys) > 0 { // 确保至少淘汰一个（如果缓存不为空）
 		numToEvict = 1
@@ -328,17 +485,17 @@ func (fc *FileCache) evictRandom() error {
 		keysToEvictSource = keysToEvictSource[:len(keysToEvictSource)-1]
 	}
 
+	evicted := make([]string, 0, len(finalKeysToEvict))
 	for keyToEvict := range finalKeysToEvict {
-		if err := fc.removeUnsafe(keyToEvict); err != nil {
-			// log error but continue
-		}
+		delete(fc.keys, keyToEvict)
+		evicted = append(evicted, keyToEvict)
 	}
 
-	return nil
+	return evicted
 }
 
 // evictOldest 淘汰最旧的缓存项
-func (fc *FileCache) evictOldest() error {
+func (fc *FileCache) evictOldest() []string {
 	if len(fc.keys) == 0 {
 		return nil
 	}
@@ -362,16 +519,17 @@ func (fc *FileCache) evictOldest() error {
 		return items[i].expiration < items[j].expiration
 	})
 
+	evicted := make([]string, 0, numToEvict)
 	for i := 0; i < numToEvict && i < len(items); i++ {
-		if err := fc.removeUnsafe(items[i].key); err != nil {
-			// 考虑记录错误，但继续淘汰其他项
-		}
+		delete(fc.keys, items[i].key)
+		evicted = append(evicted, items[i].key)
 	}
 
-	return nil
+	return evicted
 }
 
-func (fc *FileCache) evictCache() error {
+// evictCache 从索引中淘汰部分缓存项并返回被淘汰的键，文件由调用方在释放全局锁后通过removeFiles删除
+func (fc *FileCache) evictCache() []string {
 	if len(fc.keys) < fc.maxItems {
 		return nil
 	}
@@ -382,49 +540,101 @@ func (fc *FileCache) evictCache() error {
 	}
 }
 
-// removeUnsafe 不加锁的删除方法（内部使用）
-func (fc *FileCache) removeUnsafe(key string) error {
-	hashedKey := fc.getHash(key)
-	filePath := filepath.Join(fc.dir, hashedKey)
-	delete(fc.keys, key)
+// removeStale 删除过期或损坏的缓存项，仅当索引中仍是同一个缓存项时才删除并发出event事件，返回是否删除
+func (fc *FileCache) removeStale(header CacheHeader, event EventType) bool {
+	lock := fc.keyLock(header.Key)
+	lock.Lock()
+	defer lock.Unlock()
 
-	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
+	fc.mu.Lock()
+	// 再次检查，防止在获取锁的过程中状态变化
+	current, exists := fc.keys[header.Key]
+	sameItem := exists && current.Expiration == header.Expiration // 确保是同一个缓存项
+	if sameItem {
+		delete(fc.keys, header.Key)
+		fc.notify(event, header.Key)
 	}
-	return nil
+	fc.mu.Unlock()
+
+	if sameItem {
+		os.Remove(fc.filePath(header.Key)) // 忽略错误，文件可能已经不存在
+	}
+	return sameItem
+}
+
+// removeFiles 删除已经从索引中移除的键对应的文件
+// 删除前获取该键的分段锁并确认它没有被重新写入，避免误删并发Set刚写入的新文件
+func (fc *FileCache) removeFiles(keys []string) error {
+	var firstErr error
+	for _, key := range keys {
+		lock := fc.keyLock(key)
+		lock.Lock()
+
+		fc.mu.RLock()
+		_, reinserted := fc.keys[key]
+		fc.mu.RUnlock()
+
+		if !reinserted {
+			if err := os.Remove(fc.filePath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) && firstErr == nil {
+				firstErr = err
+			}
+		}
+		lock.Unlock()
+	}
+	return firstErr
+}
+
+// dropEvicted 删除被淘汰项的文件，淘汰事件已经在更新索引时发出
+func (fc *FileCache) dropEvicted(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	fc.removeFiles(keys)
+	fc.stats.evictions.Add(int64(len(keys)))
 }
 
 // CleanExpired 清理所有过期缓存
 func (fc *FileCache) CleanExpired() error {
 	fc.mu.Lock()
-	defer fc.mu.Unlock()
-
 	now := time.Now().UnixNano()
 	expiredKeys := make([]string, 0)
 
 	for key, header := range fc.keys {
 		if now > header.Expiration {
 			expiredKeys = append(expiredKeys, key)
+			delete(fc.keys, key)
 		}
 	}
+	fc.notifyAll(EventExpire, expiredKeys)
+	fc.mu.Unlock()
 
-	for _, key := range expiredKeys {
-		fc.removeUnsafe(key) // 忽略错误，继续清理
-	}
-
+	fc.removeFiles(expiredKeys) // 忽略错误，继续清理
+	fc.stats.expirations.Add(int64(len(expiredKeys)))
 	return nil
 }
 
 // Remove 删除指定缓存
 func (fc *FileCache) Remove(key string) error {
+	lock := fc.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
 	fc.mu.Lock()
-	defer fc.mu.Unlock()
+	_, exists := fc.keys[key]
+	delete(fc.keys, key)
+	if exists {
+		fc.notify(EventRemove, key)
+	}
+	fc.mu.Unlock()
 
-	if _, exists := fc.keys[key]; !exists {
+	if !exists {
 		return nil
 	}
 
-	return fc.removeUnsafe(key)
+	if err := os.Remove(fc.filePath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return fc.syncDirIfNeeded()
 }
 
 // Size 获取当前缓存项数量
@@ -437,22 +647,37 @@ func (fc *FileCache) Size() int {
 // Clear 清空所有缓存
 func (fc *FileCache) Clear() error {
 	fc.mu.Lock()
-	defer fc.mu.Unlock()
-
 	keysToRemove := make([]string, 0, len(fc.keys))
 	for key := range fc.keys {
 		keysToRemove = append(keysToRemove, key)
 	}
+	fc.keys = make(map[string]CacheHeader)
+	fc.notifyAll(EventRemove, keysToRemove)
+	fc.mu.Unlock()
 
-	for _, key := range keysToRemove {
-		fc.removeUnsafe(key) // 忽略错误，继续清理
-	}
-	// fc.keys = make(map[string]CacheHeader) // 确保map被清空, delete(fc.keys, key) 已经处理
+	fc.removeFiles(keysToRemove) // 忽略错误，继续清理
 	return nil
 }
 
 // getHash 生成键的哈希值
 func (fc *FileCache) getHash(key string) string {
+	return hashKey(key)
+}
+
+// filePath 获取键对应的缓存文件路径
+func (fc *FileCache) filePath(key string) string {
+	return filepath.Join(fc.dir, fc.getHash(key))
+}
+
+// keyLock 获取键对应的分段锁
+func (fc *FileCache) keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &fc.keyLocks[h.Sum32()%keyLockStripes]
+}
+
+// hashKey 生成键的哈希值，即缓存文件名
+func hashKey(key string) string {
 	hash := md5.Sum([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
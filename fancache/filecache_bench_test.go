@@ -0,0 +1,224 @@
+package fancache
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setupBenchCache(b *testing.B, options ...Option) *FileCache {
+	b.Helper()
+	tmpDir, err := os.MkdirTemp("", "filecache_bench_")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+	b.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	fc, err := NewFileCache(tmpDir, options...)
+	if err != nil {
+		b.Fatalf("NewFileCache failed: %v", err)
+	}
+	return fc
+}
+
+func BenchmarkFileCache_SetParallel(b *testing.B) {
+	fc := setupBenchCache(b, WithMaxItems(0))
+	value := make([]byte, 1024)
+	var counter int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := "key" + strconv.FormatInt(atomic.AddInt64(&counter, 1)%256, 10)
+			if err := fc.Set(key, value, time.Hour); err != nil {
+				b.Fatalf("Set failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkFileCache_GetParallel(b *testing.B) {
+	fc := setupBenchCache(b, WithMaxItems(0))
+	value := make([]byte, 1024)
+	for i := 0; i < 256; i++ {
+		if err := fc.Set("key"+strconv.Itoa(i), value, time.Hour); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	var counter int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var got []byte
+		for pb.Next() {
+			key := "key" + strconv.FormatInt(atomic.AddInt64(&counter, 1)%256, 10)
+			if _, err := fc.Get(key, &got); err != nil {
+				b.Fatalf("Get failed: %v", err)
+			}
+		}
+	})
+}
+
+// BenchmarkFileCache_MixedParallel mixes one Set per nine Gets. Before Set did
+// its I/O outside the global lock, every fsync stalled all concurrent Gets.
+func BenchmarkFileCache_MixedParallel(b *testing.B) {
+	fc := setupBenchCache(b, WithMaxItems(0))
+	value := make([]byte, 1024)
+	for i := 0; i < 256; i++ {
+		if err := fc.Set("key"+strconv.Itoa(i), value, time.Hour); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	var counter int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var got []byte
+		for pb.Next() {
+			n := atomic.AddInt64(&counter, 1)
+			key := "key" + strconv.FormatInt(n%256, 10)
+			if n%10 == 0 {
+				if err := fc.Set(key, value, time.Hour); err != nil {
+					b.Fatalf("Set failed: %v", err)
+				}
+				continue
+			}
+			if _, err := fc.Get(key, &got); err != nil {
+				b.Fatalf("Get failed: %v", err)
+			}
+		}
+	})
+}
+
+// slowValue sleeps while being encoded, standing in for a slow disk or fsync.
+type slowValue struct{}
+
+func (slowValue) GobEncode() ([]byte, error) {
+	time.Sleep(time.Millisecond)
+	return []byte("slow"), nil
+}
+
+// BenchmarkFileCache_GetDuringSlowSet measures Get throughput while another
+// goroutine keeps writing a slow value. With the write lock held across I/O,
+// each Get waited for the in-flight Set; now Gets only contend on the index.
+func BenchmarkFileCache_GetDuringSlowSet(b *testing.B) {
+	fc := setupBenchCache(b, WithMaxItems(0))
+	if err := fc.Set("key", "value", time.Hour); err != nil {
+		b.Fatalf("Set failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := fc.Set("slow", slowValue{}, time.Hour); err != nil {
+				b.Errorf("Set failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var got string
+		for pb.Next() {
+			if _, err := fc.Get("key", &got); err != nil {
+				b.Fatalf("Get failed: %v", err)
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-writerDone
+}
+
+var benchSizes = []struct {
+	name  string
+	items int
+}{
+	{"1k", 1000},
+	{"10k", 10000},
+	{"100k", 100000},
+}
+
+// populateBenchDir creates a cache directory holding n items, removed when
+// the benchmark ends.
+func populateBenchDir(b *testing.B, n int) string {
+	b.Helper()
+	dir := b.TempDir()
+	fc, err := NewFileCache(dir, WithMaxItems(0), WithDurability(DurabilityNone))
+	if err != nil {
+		b.Fatalf("NewFileCache failed: %v", err)
+	}
+	value := make([]byte, 256)
+	for i := 0; i < n; i++ {
+		if err := fc.Set("key"+strconv.Itoa(i), value, time.Hour); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	return dir
+}
+
+func BenchmarkFileCache_Set(b *testing.B) {
+	value := make([]byte, 256)
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			fc, err := NewFileCache(populateBenchDir(b, size.items), WithMaxItems(0))
+			if err != nil {
+				b.Fatalf("NewFileCache failed: %v", err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := fc.Set("key"+strconv.Itoa(i%size.items), value, time.Hour); err != nil {
+					b.Fatalf("Set failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFileCache_Get(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			fc, err := NewFileCache(populateBenchDir(b, size.items), WithMaxItems(0))
+			if err != nil {
+				b.Fatalf("NewFileCache failed: %v", err)
+			}
+			var got []byte
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if found, err := fc.Get("key"+strconv.Itoa(i%size.items), &got); err != nil || !found {
+					b.Fatalf("Get: found=%v err=%v", found, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFileCache_Scan measures NewFileCache, which reads every header.
+func BenchmarkFileCache_Scan(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(size.name, func(b *testing.B) {
+			dir := populateBenchDir(b, size.items)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fc, err := NewFileCache(dir, WithMaxItems(0))
+				if err != nil {
+					b.Fatalf("NewFileCache failed: %v", err)
+				}
+				if fc.Size() != size.items {
+					b.Fatalf("Size = %d; want %d", fc.Size(), size.items)
+				}
+			}
+		})
+	}
+}
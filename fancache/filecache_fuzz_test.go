@@ -0,0 +1,112 @@
+package fancache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fuzzSeedFiles returns well-formed cache files plus a few truncated and
+// mangled variants as seed corpus.
+func fuzzSeedFiles(f *testing.F) [][]byte {
+	f.Helper()
+	dir := f.TempDir()
+	fc, err := NewFileCache(dir, WithDurability(DurabilityNone))
+	if err != nil {
+		f.Fatalf("NewFileCache failed: %v", err)
+	}
+
+	if err := fc.Set("key", "value", time.Hour); err != nil {
+		f.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("key2", map[string][]int{"a": {1, 2}}, time.Hour); err != nil {
+		f.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.SetNotFound("key3", time.Hour); err != nil {
+		f.Fatalf("SetNotFound failed: %v", err)
+	}
+
+	var seeds [][]byte
+	for _, key := range []string{"key", "key2", "key3"} {
+		data, err := os.ReadFile(fc.filePath(key))
+		if err != nil {
+			f.Fatalf("ReadFile failed: %v", err)
+		}
+		seeds = append(seeds, data, data[:len(data)/2], append([]byte{0xff}, data...))
+	}
+
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(CacheHeader{Version: CurrentVersion, Key: "key"})
+	return append(seeds, buf.Bytes(), nil, []byte("not a gob stream"))
+}
+
+func FuzzReadCacheHeader(f *testing.F) {
+	for _, seed := range fuzzSeedFiles(f) {
+		f.Add(seed)
+	}
+
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		filePath := filepath.Join(dir, hashKey("key"))
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		header, err := readCacheHeader(filePath)
+		_, kind, _ := inspectCacheFile(filePath, time.Now().UnixNano(), true)
+		if err != nil && kind != IssueCorrupted {
+			t.Errorf("unreadable header classified as %s", kind)
+		}
+		if kind == IssueNone && header.Key != "key" {
+			t.Errorf("valid file with key %q under the hash of \"key\"", header.Key)
+		}
+
+		splitHeader, payload, err := splitCacheData(data)
+		if err == nil && (splitHeader != header || len(payload) > len(data)) {
+			t.Errorf("splitCacheData = %+v, %d bytes; readCacheHeader = %+v", splitHeader, len(payload), header)
+		}
+	})
+}
+
+func FuzzReadFromFile(f *testing.F) {
+	for _, seed := range fuzzSeedFiles(f) {
+		f.Add(seed)
+	}
+
+	fc, err := NewFileCache(f.TempDir())
+	if err != nil {
+		f.Fatalf("NewFileCache failed: %v", err)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(fc.filePath("key"), data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		// Decoding into several target types must fail cleanly, never panic.
+		var s string
+		fc.readFromFile("key", &s)
+		var m map[string][]int
+		fc.readFromFile("key", &m)
+		fc.readRawFromFile("key")
+
+		// Get on arbitrary content either succeeds or reports an error; a
+		// corrupted header must also drop the item from the index.
+		fc.mu.Lock()
+		fc.keys["key"] = CacheHeader{Version: CurrentVersion, Expiration: time.Now().Add(time.Hour).UnixNano(), Key: "key"}
+		fc.mu.Unlock()
+		if _, err := fc.Get("key", &s); err != nil {
+			if _, _, rawErr := fc.readRawFromFile("key"); rawErr == nil {
+				return
+			}
+			fc.mu.RLock()
+			_, indexed := fc.keys["key"]
+			fc.mu.RUnlock()
+			if indexed {
+				t.Errorf("corrupted item still indexed after Get error %v", err)
+			}
+		}
+	})
+}
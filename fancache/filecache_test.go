@@ -2,30 +2,33 @@ package fancache
 
 import (
 	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/821869798/fankit/fanpath"
 )
 
 // Helper function to create a temporary cache directory
 func setupTestCache(t *testing.T, options ...Option) (*FileCache, func()) {
 	t.Helper()
-	tmpDir, err := os.MkdirTemp("", "filecache_test_")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
+	ws := fanpath.NewTestWorkspace(t)
 
 	defaultOptions := []Option{WithMaxItems(10), WithEvictPercent(0.3)}
 	allOptions := append(defaultOptions, options...)
 
-	fc, err := NewFileCache(tmpDir, allOptions...)
+	fc, err := NewFileCache(ws.Path(), allOptions...)
 	if err != nil {
-		os.RemoveAll(tmpDir)
 		t.Fatalf("NewFileCache failed: %v", err)
 	}
 
 	cleanup := func() {
-		os.RemoveAll(tmpDir)
+		ws.Close()
 	}
 	return fc, cleanup
 }
@@ -55,6 +58,33 @@ func TestFileCache_NewFileCache(t *testing.T) {
 	}
 }
 
+func TestFileCache_OldVersionFilesWiped(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	// Version 1 wrote the header and the value with a single gob encoder.
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	enc.Encode(CacheHeader{Version: 1, Expiration: time.Now().Add(time.Hour).UnixNano(), Key: "old"})
+	enc.Encode("value")
+	oldPath := filepath.Join(fc.dir, fc.getHash("old"))
+	if err := os.WriteFile(oldPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	reloaded, err := NewFileCache(fc.dir)
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	var value string
+	if found, err := reloaded.Get("old", &value); found || err != nil {
+		t.Errorf("Get old version entry: found=%v err=%v; want a plain miss", found, err)
+	}
+	if _, err := os.Stat(oldPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("old version file was not removed: %v", err)
+	}
+}
+
 func TestFileCache_SetGetRemove(t *testing.T) {
 	fc, cleanup := setupTestCache(t)
 	defer cleanup()
@@ -168,3 +198,188 @@ func TestFileCache_SetGetRemove_Bytes(t *testing.T) {
 		t.Fatalf("Remove (bytes) non-existent key failed: %v", err)
 	}
 }
+
+// blockingValue blocks gob encoding until release is closed, simulating a
+// slow write inside Set.
+type blockingValue struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (v blockingValue) GobEncode() ([]byte, error) {
+	close(v.started)
+	<-v.release
+	return []byte("slow"), nil
+}
+
+func TestFileCache_SlowSetDoesNotBlockOtherKeys(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if err := fc.Set("fast", "value", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	slow := blockingValue{started: make(chan struct{}), release: make(chan struct{})}
+	setDone := make(chan error, 1)
+	go func() { setDone <- fc.Set("slow", slow, time.Minute) }()
+	<-slow.started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var got string
+		if found, err := fc.Get("fast", &got); err != nil || !found {
+			t.Errorf("Get during slow Set: found=%v err=%v", found, err)
+		}
+		if err := fc.Set("other", "value", time.Minute); err != nil {
+			t.Errorf("Set during slow Set failed: %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get/Set on other keys blocked by a slow Set")
+	}
+
+	close(slow.release)
+	if err := <-setDone; err != nil {
+		t.Fatalf("slow Set failed: %v", err)
+	}
+}
+
+func TestFileCache_ConcurrentAccess(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithMaxItems(20))
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("key%d", (g*50+i)%40)
+				switch i % 4 {
+				case 0, 1:
+					if err := fc.Set(key, i, time.Minute); err != nil {
+						t.Errorf("Set failed: %v", err)
+					}
+				case 2:
+					var got int
+					if _, err := fc.Get(key, &got); err != nil {
+						t.Errorf("Get failed: %v", err)
+					}
+				case 3:
+					if err := fc.Remove(key); err != nil {
+						t.Errorf("Remove failed: %v", err)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Every indexed key must have its file, and no stray files may remain.
+	if size := fc.Size(); size > 20 {
+		t.Errorf("Size = %d; want <= 20", size)
+	}
+	report, err := Verify(fc.dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Verify found issues: %+v", report.Issues)
+	}
+	if len(report.Entries) != fc.Size() {
+		t.Errorf("files on disk = %d; indexed = %d", len(report.Entries), fc.Size())
+	}
+}
+
+// TestFileCache_Stress mixes Set, SetNotFound, Get, Remove, CleanExpired and
+// both eviction strategies from many goroutines; run it with -race.
+func TestFileCache_Stress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	tests := []struct {
+		name     string
+		maxItems int
+		keys     int
+	}{
+		{"evict-oldest", 32, 64},
+		{"evict-random", RandomEvictThreshold + 10, RandomEvictThreshold + 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fc, cleanup := setupTestCache(t, WithMaxItems(test.maxItems), WithDurability(DurabilityNone))
+			defer cleanup()
+
+			events, cancel := fc.Watch("")
+			defer cancel()
+			go func() {
+				for range events {
+				}
+			}()
+
+			var wg sync.WaitGroup
+			for g := 0; g < 16; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 200; i++ {
+						key := fmt.Sprintf("key%d", (g*7919+i*31)%test.keys)
+						switch (g + i) % 8 {
+						case 0, 1, 2:
+							if err := fc.Set(key, []byte(key), time.Duration(i%5)*time.Millisecond+time.Millisecond); err != nil {
+								t.Errorf("Set failed: %v", err)
+							}
+						case 3:
+							if err := fc.SetNotFound(key, time.Minute); err != nil {
+								t.Errorf("SetNotFound failed: %v", err)
+							}
+						case 4, 5:
+							var got []byte
+							found, err := fc.Get(key, &got)
+							if err != nil && !errors.Is(err, ErrCachedNotFound) {
+								t.Errorf("Get failed: %v", err)
+							}
+							if found && string(got) != key {
+								t.Errorf("Get %q returned %q", key, got)
+							}
+						case 6:
+							if err := fc.Remove(key); err != nil {
+								t.Errorf("Remove failed: %v", err)
+							}
+						case 7:
+							if err := fc.CleanExpired(); err != nil {
+								t.Errorf("CleanExpired failed: %v", err)
+							}
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			if size := fc.Size(); size > test.maxItems {
+				t.Errorf("Size = %d; want <= %d", size, test.maxItems)
+			}
+
+			// The index and the directory must agree once everything settles.
+			report, err := Verify(fc.dir, VerifyOptions{})
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			for _, issue := range report.Issues {
+				if issue.Kind != IssueExpired {
+					t.Errorf("Verify issue %s at %s", issue.Kind, issue.Path)
+				}
+			}
+			if onDisk := len(report.Entries) + report.Count(IssueExpired); onDisk != fc.Size() {
+				t.Errorf("files on disk = %d; indexed = %d", onDisk, fc.Size())
+			}
+		})
+	}
+}
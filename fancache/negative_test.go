@@ -0,0 +1,141 @@
+package fancache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache_SetNotFound(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if err := fc.SetNotFound("missing", time.Minute); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+
+	var value string
+	found, err := fc.Get("missing", &value)
+	if found || !errors.Is(err, ErrCachedNotFound) {
+		t.Fatalf("Get negative entry: found=%v err=%v; want ErrCachedNotFound", found, err)
+	}
+
+	// A plain miss is still reported as (false, nil).
+	if found, err := fc.Get("unknown", &value); found || err != nil {
+		t.Fatalf("Get unknown key: found=%v err=%v", found, err)
+	}
+
+	// Negative entries survive a reload and are overwritten by Set.
+	reloaded, err := NewFileCache(fc.dir)
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	if _, err := reloaded.Get("missing", &value); !errors.Is(err, ErrCachedNotFound) {
+		t.Fatalf("Get after reload: err=%v; want ErrCachedNotFound", err)
+	}
+	if err := reloaded.Set("missing", "now here", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if found, err := reloaded.Get("missing", &value); !found || err != nil || value != "now here" {
+		t.Fatalf("Get after Set: found=%v err=%v value=%q", found, err, value)
+	}
+
+	// Expired negative entries turn into plain misses.
+	if err := fc.SetNotFound("short", -time.Second); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+	if found, err := fc.Get("short", &value); found || err != nil {
+		t.Fatalf("Get expired negative entry: found=%v err=%v", found, err)
+	}
+}
+
+func TestFileCache_Stats(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithMaxItems(3))
+	defer cleanup()
+
+	var value string
+	fc.Set("a", "1", time.Minute)
+	fc.Set("b", "2", time.Minute)
+	fc.SetNotFound("c", time.Minute)
+	fc.Get("a", &value) // hit
+	fc.Get("c", &value) // negative hit
+	fc.Get("c", &value) // negative hit
+	fc.Get("z", &value) // miss
+	fc.Set("d", "4", time.Minute)
+
+	stats := fc.Stats()
+	want := Stats{
+		Hits:          1,
+		NegativeHits:  2,
+		Misses:        1,
+		Sets:          3,
+		NegativeSets:  1,
+		Evictions:     1,
+		Expirations:   0,
+		Items:         3,
+		NegativeItems: stats.NegativeItems, // depends on which item was evicted
+	}
+	if stats != want {
+		t.Errorf("Stats = %+v; want %+v", stats, want)
+	}
+}
+
+func TestFileCache_NegativeEntrySnapshot(t *testing.T) {
+	src, cleanupSrc := setupTestCache(t)
+	defer cleanupSrc()
+	dst, cleanupDst := setupTestCache(t)
+	defer cleanupDst()
+
+	if err := src.SetNotFound("missing", time.Minute); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if _, err := dst.Import(&buf, MergeOverwrite); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	var value string
+	if _, err := dst.Get("missing", &value); !errors.Is(err, ErrCachedNotFound) {
+		t.Fatalf("Get imported negative entry: err=%v; want ErrCachedNotFound", err)
+	}
+	if stats := dst.Stats(); stats.NegativeItems != 1 {
+		t.Errorf("NegativeItems = %d; want 1", stats.NegativeItems)
+	}
+}
+
+func TestFileCache_ReadsVersion2Files(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	// Version 2 headers had no NotFound field.
+	type v2Header struct {
+		Version    byte
+		Expiration int64
+		Key        string
+	}
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(v2Header{Version: 2, Expiration: time.Now().Add(time.Hour).UnixNano(), Key: "v2"})
+	gob.NewEncoder(&buf).Encode("value")
+	if err := os.WriteFile(filepath.Join(fc.dir, fc.getHash("v2")), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	reloaded, err := NewFileCache(fc.dir)
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	var value string
+	if found, err := reloaded.Get("v2", &value); !found || err != nil || value != "value" {
+		t.Fatalf("Get version 2 entry: found=%v err=%v value=%q", found, err, value)
+	}
+	if report, err := Verify(fc.dir, VerifyOptions{}); err != nil || len(report.Issues) != 0 {
+		t.Errorf("Verify version 2 entry: issues=%+v err=%v", report.Issues, err)
+	}
+}
@@ -0,0 +1,208 @@
+package fancache
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 快照中每个缓存项对应一个tar文件条目，文件名为哈希后的键，内容为头部之后已编码的数据，
+// 原始键、剩余有效期等信息记录在PAX扩展头中
+const (
+	snapshotPAXVersion  = "FANCACHE.version"
+	snapshotPAXKey      = "FANCACHE.key"
+	snapshotPAXTTL      = "FANCACHE.ttl"
+	snapshotPAXNotFound = "FANCACHE.notfound"
+)
+
+var ErrSnapshotInvalid = errors.New("invalid cache snapshot")
+
+// MergePolicy 导入快照时，快照中的项与缓存中已有的项冲突时的合并策略
+type MergePolicy int
+
+const (
+	MergeOverwrite    MergePolicy = iota // 总是使用快照中的项覆盖已有项
+	MergeKeepExisting                    // 保留缓存中已有的未过期项
+	MergeKeepLonger                      // 保留过期时间更晚的项
+)
+
+// Export 把所有未过期的缓存项导出为tar格式的快照，每项记录导出时的剩余有效期
+// 只在复制键列表时持有全局锁，每项在它的分段锁下读取，写入w时不持有任何锁，因此慢速的w不会阻塞其他操作；
+// 每项的数据和有效期都取自同一个完整的缓存文件，未完成的临时文件不会被导出，导出期间新增的键不包含在快照中
+func (fc *FileCache) Export(w io.Writer) error {
+	fc.mu.RLock()
+	keys := make([]string, 0, len(fc.keys))
+	for key := range fc.keys {
+		keys = append(keys, key)
+	}
+	fc.mu.RUnlock()
+	sort.Strings(keys)
+
+	now := time.Now()
+	tw := tar.NewWriter(w)
+	for _, key := range keys {
+		header, payload, err := fc.readSnapshotItem(key)
+		if err != nil {
+			// 文件丢失或损坏的项不属于有效数据，跳过即可
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrCacheCorrupted) || errors.Is(err, ErrKeyMismatch) {
+				continue
+			}
+			return fmt.Errorf("failed to read cache item %q: %w", key, err)
+		}
+		remaining := header.Expiration - now.UnixNano()
+		if remaining <= 0 {
+			continue
+		}
+
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     fc.getHash(key),
+			Mode:     0644,
+			Size:     int64(len(payload)),
+			ModTime:  now,
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				snapshotPAXVersion: strconv.Itoa(CurrentVersion),
+				snapshotPAXKey:     key,
+				snapshotPAXTTL:     strconv.FormatInt(remaining, 10),
+			},
+		}
+		if header.NotFound {
+			hdr.PAXRecords[snapshotPAXNotFound] = "1"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write snapshot header: %w", err)
+		}
+		if _, err := tw.Write(payload); err != nil {
+			return fmt.Errorf("failed to write snapshot data: %w", err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// readSnapshotItem 在键的分段锁下读取缓存文件，键已被删除时返回fs.ErrNotExist
+// 分段锁保证读取期间同一个键的文件不会被替换或删除
+func (fc *FileCache) readSnapshotItem(key string) (CacheHeader, []byte, error) {
+	lock := fc.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	fc.mu.RLock()
+	_, exists := fc.keys[key]
+	fc.mu.RUnlock()
+	if !exists {
+		return CacheHeader{}, nil, fs.ErrNotExist
+	}
+	return fc.readRawFromFile(key)
+}
+
+// Import 从Export导出的快照中加载缓存项，返回实际导入的数量
+// 每项的过期时间以导入时刻加上导出时的剩余有效期重新计算，超过maxItems时按Set相同的策略淘汰
+func (fc *FileCache) Import(r io.Reader, policy MergePolicy) (int, error) {
+	tr := tar.NewReader(r)
+	imported := 0
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return imported, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+
+		key, ttl, notFound, err := fc.parseSnapshotHeader(hdr)
+		if err != nil {
+			return imported, err
+		}
+
+		payload, err := io.ReadAll(tr)
+		if err != nil {
+			return imported, fmt.Errorf("failed to read snapshot data: %w", err)
+		}
+		if ttl <= 0 {
+			continue
+		}
+
+		header := CacheHeader{
+			Version:    CurrentVersion,
+			Expiration: time.Now().Add(ttl).UnixNano(),
+			Key:        key,
+			NotFound:   notFound,
+		}
+		ok, err := fc.importItem(header, payload, policy)
+		if err != nil {
+			return imported, err
+		}
+		if ok {
+			imported++
+		}
+	}
+
+	return imported, nil
+}
+
+// parseSnapshotHeader 解析并校验快照条目的头部，返回原始键、剩余有效期以及是否为负缓存项
+func (fc *FileCache) parseSnapshotHeader(hdr *tar.Header) (string, time.Duration, bool, error) {
+	if hdr.Typeflag != tar.TypeReg {
+		return "", 0, false, fmt.Errorf("%w: unexpected entry %q", ErrSnapshotInvalid, hdr.Name)
+	}
+
+	version, err := strconv.Atoi(hdr.PAXRecords[snapshotPAXVersion])
+	if err != nil || !readableVersion(version) {
+		return "", 0, false, fmt.Errorf("%w: unsupported version %q in entry %q", ErrSnapshotInvalid, hdr.PAXRecords[snapshotPAXVersion], hdr.Name)
+	}
+
+	key := hdr.PAXRecords[snapshotPAXKey]
+	if key == "" || fc.getHash(key) != hdr.Name {
+		return "", 0, false, fmt.Errorf("%w: key mismatch in entry %q", ErrSnapshotInvalid, hdr.Name)
+	}
+
+	ttl, err := strconv.ParseInt(hdr.PAXRecords[snapshotPAXTTL], 10, 64)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("%w: invalid ttl in entry %q", ErrSnapshotInvalid, hdr.Name)
+	}
+
+	return key, time.Duration(ttl), hdr.PAXRecords[snapshotPAXNotFound] == "1", nil
+}
+
+// importItem 按合并策略写入一个快照项，返回是否实际写入
+func (fc *FileCache) importItem(header CacheHeader, payload []byte, policy MergePolicy) (bool, error) {
+	lock := fc.keyLock(header.Key)
+	lock.Lock()
+
+	fc.mu.RLock()
+	existing, exists := fc.keys[header.Key]
+	fc.mu.RUnlock()
+
+	if exists && existing.Expiration > time.Now().UnixNano() {
+		keep := false
+		switch policy {
+		case MergeKeepExisting:
+			keep = true
+		case MergeKeepLonger:
+			keep = existing.Expiration >= header.Expiration
+		}
+		if keep {
+			lock.Unlock()
+			return false, nil
+		}
+	}
+
+	evicted, err := fc.storeLocked(header, func(filePath string) error {
+		return fc.writeRawToFile(filePath, header, payload)
+	})
+	lock.Unlock()
+
+	fc.dropEvicted(evicted)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
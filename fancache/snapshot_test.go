@@ -0,0 +1,206 @@
+package fancache
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type snapshotTestValue struct {
+	Name  string
+	Items []int
+}
+
+func TestFileCache_ExportImport(t *testing.T) {
+	src, cleanupSrc := setupTestCache(t)
+	defer cleanupSrc()
+	dst, cleanupDst := setupTestCache(t)
+	defer cleanupDst()
+
+	if err := src.Set("struct", snapshotTestValue{Name: "a", Items: []int{1, 2, 3}}, time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.Set("string", "hello", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.Set("expired", "gone", -time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	// A leftover temp file must not end up in the snapshot.
+	if err := os.WriteFile(filepath.Join(src.dir, src.getHash("partial")+".tmp"), []byte("garbage"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	n, err := dst.Import(&buf, MergeOverwrite)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Import: imported %d items; want 2", n)
+	}
+
+	var sv snapshotTestValue
+	if found, err := dst.Get("struct", &sv); err != nil || !found {
+		t.Fatalf("Get struct: found=%v err=%v", found, err)
+	}
+	if sv.Name != "a" || len(sv.Items) != 3 || sv.Items[2] != 3 {
+		t.Errorf("Get struct: got %+v", sv)
+	}
+
+	var s string
+	if found, err := dst.Get("string", &s); err != nil || !found || s != "hello" {
+		t.Fatalf("Get string: found=%v err=%v value=%q", found, err, s)
+	}
+	if found, _ := dst.Get("expired", &s); found {
+		t.Error("Get: expired item was imported")
+	}
+
+	// The remaining TTL is rebased on the importing side, not copied verbatim.
+	expiration := time.Unix(0, dst.keys["string"].Expiration)
+	if d := time.Until(expiration); d <= 0 || d > time.Minute {
+		t.Errorf("imported expiration %v out of range", d)
+	}
+
+	// Imported items survive a reload from disk.
+	reloaded, err := NewFileCache(dst.dir)
+	if err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+	if found, err := reloaded.Get("struct", &sv); err != nil || !found {
+		t.Fatalf("Get after reload: found=%v err=%v", found, err)
+	}
+}
+
+func TestFileCache_ImportMergePolicy(t *testing.T) {
+	src, cleanupSrc := setupTestCache(t)
+	defer cleanupSrc()
+
+	if err := src.Set("key", "snapshot", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	var snapshot bytes.Buffer
+	if err := src.Export(&snapshot); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	tests := []struct {
+		policy   MergePolicy
+		existing time.Duration
+		want     string
+	}{
+		{MergeOverwrite, 2 * time.Hour, "snapshot"},
+		{MergeKeepExisting, time.Minute, "existing"},
+		{MergeKeepLonger, time.Minute, "snapshot"},
+		{MergeKeepLonger, 2 * time.Hour, "existing"},
+	}
+
+	for _, test := range tests {
+		dst, cleanup := setupTestCache(t)
+		if err := dst.Set("key", "existing", test.existing); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if _, err := dst.Import(bytes.NewReader(snapshot.Bytes()), test.policy); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		var got string
+		if _, err := dst.Get("key", &got); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("policy %d with existing ttl %v: got %q; want %q", test.policy, test.existing, got, test.want)
+		}
+		cleanup()
+	}
+}
+
+func TestFileCache_ImportRespectsMaxItems(t *testing.T) {
+	src, cleanupSrc := setupTestCache(t, WithMaxItems(100))
+	defer cleanupSrc()
+	dst, cleanupDst := setupTestCache(t, WithMaxItems(5))
+	defer cleanupDst()
+
+	for i := 0; i < 20; i++ {
+		if err := src.Set(string(rune('a'+i)), i, time.Duration(i+1)*time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if _, err := dst.Import(&buf, MergeOverwrite); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if size := dst.Size(); size > 5 {
+		t.Errorf("Size after import = %d; want <= 5", size)
+	}
+}
+
+func TestFileCache_ImportInvalidSnapshot(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if _, err := fc.Import(bytes.NewReader([]byte("not a tar archive")), MergeOverwrite); err == nil {
+		t.Error("Import: expected error for invalid snapshot")
+	}
+}
+
+// blockingWriter blocks every Write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestFileCache_ExportSlowWriterDoesNotBlock(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		if err := fc.Set(fmt.Sprintf("key%d", i), "value", time.Hour); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	exported := make(chan error, 1)
+	go func() { exported <- fc.Export(w) }()
+	<-w.started
+
+	// Export is stuck in Write; Set, Get and Remove on the same keys must still go through.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var value string
+		fc.Set("key0", "new", time.Hour)
+		fc.Set("other", "value", time.Hour)
+		fc.Get("key1", &value)
+		fc.Remove("key2")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache operations blocked by a slow Export writer")
+	}
+
+	close(w.release)
+	if err := <-exported; err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package fancache
+
+import "sync/atomic"
+
+// Stats 缓存的运行时统计，计数从NewFileCache开始累计
+type Stats struct {
+	Hits          int64 // Get命中正常缓存项的次数
+	NegativeHits  int64 // Get命中负缓存项（返回ErrCachedNotFound）的次数
+	Misses        int64 // Get未命中的次数，包括过期和文件损坏
+	Sets          int64 // Set成功的次数
+	NegativeSets  int64 // SetNotFound成功的次数
+	Evictions     int64 // 被淘汰的缓存项数量
+	Expirations   int64 // 过期后被清理的缓存项数量
+	Items         int   // 当前的缓存项数量，包括负缓存项
+	NegativeItems int   // 当前的负缓存项数量
+}
+
+// cacheStats 运行时计数器
+type cacheStats struct {
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	misses       atomic.Int64
+	sets         atomic.Int64
+	negativeSets atomic.Int64
+	evictions    atomic.Int64
+	expirations  atomic.Int64
+}
+
+// Stats 获取缓存的运行时统计
+func (fc *FileCache) Stats() Stats {
+	stats := Stats{
+		Hits:         fc.stats.hits.Load(),
+		NegativeHits: fc.stats.negativeHits.Load(),
+		Misses:       fc.stats.misses.Load(),
+		Sets:         fc.stats.sets.Load(),
+		NegativeSets: fc.stats.negativeSets.Load(),
+		Evictions:    fc.stats.evictions.Load(),
+		Expirations:  fc.stats.expirations.Load(),
+	}
+
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	stats.Items = len(fc.keys)
+	for _, header := range fc.keys {
+		if header.NotFound {
+			stats.NegativeItems++
+		}
+	}
+	return stats
+}
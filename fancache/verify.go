@@ -0,0 +1,245 @@
+package fancache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IssueKind 缓存目录检查时发现的问题类型
+type IssueKind int
+
+const (
+	IssueNone            IssueKind = iota
+	IssueOrphanedTemp              // 写入中断后残留的临时文件
+	IssueCorrupted                 // 无法解码的文件
+	IssueHashMismatch              // 文件名与头部中键的哈希不一致
+	IssueVersionMismatch           // 文件版本无法读取：过旧或比当前版本新
+	IssueExpired                   // 已过期的缓存项
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueNone:
+		return "ok"
+	case IssueOrphanedTemp:
+		return "orphaned-temp"
+	case IssueCorrupted:
+		return "corrupted"
+	case IssueHashMismatch:
+		return "hash-mismatch"
+	case IssueVersionMismatch:
+		return "version-mismatch"
+	case IssueExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("IssueKind(%d)", int(k))
+	}
+}
+
+// Issue 检查发现的单个问题
+type Issue struct {
+	Kind     IssueKind
+	Path     string
+	Key      string // 能解码出头部时为原始键，否则为空
+	Err      error  // 导致问题的底层错误，可能为nil
+	Repaired bool   // 是否已经修复（删除）
+}
+
+// Entry 缓存目录中的有效缓存项
+type Entry struct {
+	Key        string
+	Path       string
+	Expiration time.Time
+	Size       int64
+	NotFound   bool // 是否为负缓存项
+}
+
+// VerifyOptions 检查选项
+type VerifyOptions struct {
+	Repair bool // 为true时删除所有有问题的文件，否则只报告
+}
+
+// VerifyReport 检查结果
+type VerifyReport struct {
+	Entries []Entry // 按键排序的有效缓存项
+	Issues  []Issue // 按路径排序的问题列表
+}
+
+// Bytes 有效缓存项占用的总字节数
+func (r *VerifyReport) Bytes() int64 {
+	var total int64
+	for _, entry := range r.Entries {
+		total += entry.Size
+	}
+	return total
+}
+
+// Count 指定类型问题的数量
+func (r *VerifyReport) Count(kind IssueKind) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Verify 检查缓存目录，报告残留的临时文件、损坏的文件、哈希不一致、版本不一致以及过期的缓存项
+// 只有opts.Repair为true时才会删除有问题的文件；修复应在没有进程使用该缓存目录时进行，
+// 否则正在写入的临时文件也会被当作残留文件删除
+func Verify(dir string, opts VerifyOptions) (*VerifyReport, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	now := time.Now().UnixNano()
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		filePath := filepath.Join(dir, dirEntry.Name())
+		header, kind, err := inspectCacheFile(filePath, now, true)
+		if kind == IssueNone {
+			var size int64
+			if info, err := dirEntry.Info(); err == nil {
+				size = info.Size()
+			}
+			report.Entries = append(report.Entries, Entry{
+				Key:        header.Key,
+				Path:       filePath,
+				Expiration: time.Unix(0, header.Expiration),
+				Size:       size,
+				NotFound:   header.NotFound,
+			})
+			continue
+		}
+
+		issue := Issue{Kind: kind, Path: filePath, Key: header.Key, Err: err}
+		if opts.Repair {
+			if err := os.Remove(filePath); err == nil || errors.Is(err, os.ErrNotExist) {
+				issue.Repaired = true
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Key < report.Entries[j].Key
+	})
+	sort.Slice(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Path < report.Issues[j].Path
+	})
+
+	return report, nil
+}
+
+// Peek 只读地获取缓存目录dir中key的值，不打开缓存，也不删除过期或损坏的文件，可以用于检查正在使用的缓存目录
+// 过期的缓存项同样会被读取，由调用方根据返回的Entry.Expiration判断；头部无法读取时Entry为零值
+// 缓存项不存在时返回的错误满足errors.Is(err, fs.ErrNotExist)，负缓存项返回ErrCachedNotFound
+func Peek(dir, key string, value interface{}) (Entry, error) {
+	if key == "" {
+		return Entry{}, errors.New("cache key cannot be empty")
+	}
+
+	filePath := filepath.Join(dir, hashKey(key))
+	header, kind, err := inspectCacheFile(filePath, time.Now().UnixNano(), false)
+	if kind != IssueNone && kind != IssueExpired {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Key:        header.Key,
+		Path:       filePath,
+		Expiration: time.Unix(0, header.Expiration),
+		NotFound:   header.NotFound,
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		entry.Size = info.Size()
+	}
+	if header.NotFound {
+		return entry, ErrCachedNotFound
+	}
+	return entry, readCacheFile(filePath, key, value)
+}
+
+// inspectCacheFile 检查单个缓存文件，返回其头部和问题类型，IssueNone表示文件有效
+// checkPayload为true时还会完整解码头部之后的数据，发现被截断或损坏的数据；否则只读取头部
+func inspectCacheFile(filePath string, now int64, checkPayload bool) (CacheHeader, IssueKind, error) {
+	fileName := filepath.Base(filePath)
+	if strings.HasSuffix(fileName, ".tmp") {
+		return CacheHeader{}, IssueOrphanedTemp, nil
+	}
+
+	header, err := readCacheHeader(filePath)
+	if err != nil {
+		return CacheHeader{}, IssueCorrupted, err
+	}
+
+	// 验证原始键的完整性
+	if header.Key == "" {
+		return header, IssueCorrupted, ErrCacheCorrupted
+	}
+
+	// 验证哈希一致性（防止文件名被篡改）
+	if hashKey(header.Key) != fileName {
+		return header, IssueHashMismatch, ErrKeyMismatch
+	}
+
+	if !readableVersion(int(header.Version)) {
+		return header, IssueVersionMismatch, fmt.Errorf("unsupported version %d", header.Version)
+	}
+
+	if checkPayload {
+		if err := checkCachePayload(filePath); err != nil {
+			return header, IssueCorrupted, err
+		}
+	}
+
+	if now > header.Expiration {
+		return header, IssueExpired, nil
+	}
+
+	return header, IssueNone, nil
+}
+
+// checkCachePayload 解码并丢弃（DecodeValue传入零值）缓存文件头部之后的数据，确认数据完整且之后没有多余的内容
+// 负缓存项只有头部，头部之后必须直接结束
+func checkCachePayload(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var header CacheHeader
+	if err := gob.NewDecoder(reader).Decode(&header); err != nil {
+		return fmt.Errorf("%w: corrupted header: %v", ErrCacheCorrupted, err)
+	}
+	if !header.NotFound {
+		if err := gob.NewDecoder(reader).DecodeValue(reflect.Value{}); err != nil {
+			return fmt.Errorf("%w: corrupted data: %v", ErrCacheCorrupted, err)
+		}
+	}
+	if _, err := reader.Peek(1); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: trailing data after payload", ErrCacheCorrupted)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package fancache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if err := fc.Set("good", "value", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("expired", "value", -time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("renamed", "value", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	writeFile := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(fc.dir, name), data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	writeFile(fc.getHash("orphan")+".tmp", []byte("partial"))
+	writeFile(fc.getHash("garbage"), []byte("not a gob stream"))
+	if err := os.Rename(filepath.Join(fc.dir, fc.getHash("renamed")), filepath.Join(fc.dir, fc.getHash("other"))); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fc.writeToFile(filepath.Join(fc.dir, fc.getHash("old")), CacheHeader{Version: CurrentVersion + 1, Expiration: time.Now().Add(time.Hour).UnixNano(), Key: "old"}, "value"); err != nil {
+		t.Fatalf("writeToFile failed: %v", err)
+	}
+
+	// Reopening with WithKeepInvalidFiles must not clean anything up before fsck.
+	if _, err := NewFileCache(fc.dir, WithKeepInvalidFiles()); err != nil {
+		t.Fatalf("NewFileCache failed: %v", err)
+	}
+
+	report, err := Verify(fc.dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(report.Entries) != 1 || report.Entries[0].Key != "good" {
+		t.Errorf("Entries = %+v; want only \"good\"", report.Entries)
+	}
+	for kind, want := range map[IssueKind]int{
+		IssueOrphanedTemp:    1,
+		IssueCorrupted:       1,
+		IssueHashMismatch:    1,
+		IssueVersionMismatch: 1,
+		IssueExpired:         1,
+	} {
+		if got := report.Count(kind); got != want {
+			t.Errorf("Count(%s) = %d; want %d", kind, got, want)
+		}
+	}
+
+	// Without Repair nothing is touched.
+	entries, _ := os.ReadDir(fc.dir)
+	if len(entries) != 6 {
+		t.Errorf("files after read-only Verify = %d; want 6", len(entries))
+	}
+
+	report, err = Verify(fc.dir, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Verify with repair failed: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			t.Errorf("issue %s at %s not repaired", issue.Kind, issue.Path)
+		}
+	}
+	entries, _ = os.ReadDir(fc.dir)
+	if len(entries) != 1 {
+		t.Errorf("files after repair = %d; want 1", len(entries))
+	}
+}
+
+func TestVerifyTruncatedPayload(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if err := fc.Set("truncated", strings.Repeat("value", 100), time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("trailing", "value", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.SetNotFound("negative", time.Hour); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+
+	// The header stays intact; only the payload is cut short.
+	truncated := filepath.Join(fc.dir, fc.getHash("truncated"))
+	info, err := os.Stat(truncated)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.Truncate(truncated, info.Size()-10); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	trailing, err := os.OpenFile(filepath.Join(fc.dir, fc.getHash("trailing")), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	trailing.Write([]byte("garbage"))
+	trailing.Close()
+
+	report, err := Verify(fc.dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := report.Count(IssueCorrupted); got != 2 {
+		t.Errorf("Count(corrupted) = %d; want 2 (issues %+v)", got, report.Issues)
+	}
+	if len(report.Entries) != 1 || report.Entries[0].Key != "negative" {
+		t.Errorf("Entries = %+v; want only the negative entry", report.Entries)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	fc, cleanup := setupTestCache(t)
+	defer cleanup()
+
+	if err := fc.Set("live", "value", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("expired", "old", -time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.SetNotFound("negative", time.Hour); err != nil {
+		t.Fatalf("SetNotFound failed: %v", err)
+	}
+
+	var got string
+	entry, err := Peek(fc.dir, "live", &got)
+	if err != nil || got != "value" || entry.Key != "live" || entry.Size == 0 {
+		t.Errorf("Peek(live) = %+v, %q, %v", entry, got, err)
+	}
+
+	// Expired items are still read and left on disk.
+	entry, err = Peek(fc.dir, "expired", &got)
+	if err != nil || got != "old" || !entry.Expiration.Before(time.Now()) {
+		t.Errorf("Peek(expired) = %+v, %q, %v", entry, got, err)
+	}
+	if _, err := os.Stat(filepath.Join(fc.dir, fc.getHash("expired"))); err != nil {
+		t.Errorf("Peek removed the expired file: %v", err)
+	}
+
+	if entry, err := Peek(fc.dir, "negative", &got); !errors.Is(err, ErrCachedNotFound) || !entry.NotFound {
+		t.Errorf("Peek(negative) = %+v, %v; want ErrCachedNotFound", entry, err)
+	}
+	if _, err := Peek(fc.dir, "missing", &got); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Peek(missing) error = %v; want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,119 @@
+package fancache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DefaultWatchBuffer = 64 // 每个订阅者默认的事件缓冲区大小
+
+// EventType 缓存事件类型
+type EventType int
+
+const (
+	EventSet     EventType = iota + 1 // 写入缓存项（包括Import导入）
+	EventRemove                       // 通过Remove或Clear删除
+	EventEvict                        // 超过maxItems时被淘汰
+	EventExpire                       // 过期后被清理
+	EventCorrupt                      // 读取时发现文件丢失或损坏而被清理
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventRemove:
+		return "remove"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventCorrupt:
+		return "corrupt"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event 缓存变化事件
+type Event struct {
+	Type    EventType
+	Key     string
+	Time    time.Time
+	Dropped int // 在此事件之前，由于订阅者处理过慢而被丢弃的事件数量
+}
+
+// watcher 一个订阅者
+type watcher struct {
+	prefix  string
+	ch      chan Event
+	dropped int
+}
+
+// WithWatchBuffer 设置Watch返回的每个channel的缓冲区大小
+func WithWatchBuffer(size int) Option {
+	return func(fc *FileCache) {
+		if size > 0 {
+			fc.watchBuffer = size
+		}
+	}
+}
+
+// Watch 订阅键以prefix开头的缓存项的变化事件，prefix为空时订阅所有事件
+// 发送事件从不阻塞缓存操作：channel的缓冲区满时直接丢弃新的事件，丢弃的数量累计在下一个成功送达的事件的Dropped字段中，
+// 订阅者发现Dropped不为0时应当认为自己的状态已经不可靠，按需重新读取缓存
+// 调用cancel后停止订阅并关闭channel，cancel可以重复调用
+func (fc *FileCache) Watch(prefix string) (<-chan Event, func()) {
+	w := &watcher{
+		prefix: prefix,
+		ch:     make(chan Event, fc.watchBuffer),
+	}
+
+	fc.watchMu.Lock()
+	if fc.watchers == nil {
+		fc.watchers = make(map[*watcher]struct{})
+	}
+	fc.watchers[w] = struct{}{}
+	fc.watchMu.Unlock()
+
+	cancel := func() {
+		fc.watchMu.Lock()
+		defer fc.watchMu.Unlock()
+		if _, ok := fc.watchers[w]; ok {
+			delete(fc.watchers, w)
+			close(w.ch)
+		}
+	}
+	return w.ch, cancel
+}
+
+// notify 向所有匹配的订阅者发送事件
+func (fc *FileCache) notify(eventType EventType, key string) {
+	fc.watchMu.Lock()
+	defer fc.watchMu.Unlock()
+
+	if len(fc.watchers) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for w := range fc.watchers {
+		if !strings.HasPrefix(key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- Event{Type: eventType, Key: key, Time: now, Dropped: w.dropped}:
+			w.dropped = 0
+		default:
+			w.dropped++
+		}
+	}
+}
+
+// notifyAll 为每个键发送同一类型的事件
+func (fc *FileCache) notifyAll(eventType EventType, keys []string) {
+	for _, key := range keys {
+		fc.notify(eventType, key)
+	}
+}
@@ -0,0 +1,213 @@
+package fancache
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return Event{}
+}
+
+func expectNoEvent(t *testing.T, ch <-chan Event) {
+	t.Helper()
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %s %q", event.Type, event.Key)
+	default:
+	}
+}
+
+func TestFileCache_Watch(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithMaxItems(3), WithEvictPercent(0.3))
+	defer cleanup()
+
+	events, cancel := fc.Watch("user:")
+	defer cancel()
+
+	if err := fc.Set("user:1", "a", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("other", "b", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if e := receiveEvent(t, events); e.Type != EventSet || e.Key != "user:1" {
+		t.Errorf("got %s %q; want set \"user:1\"", e.Type, e.Key)
+	}
+	expectNoEvent(t, events)
+
+	if err := fc.Remove("user:1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if e := receiveEvent(t, events); e.Type != EventRemove || e.Key != "user:1" {
+		t.Errorf("got %s %q; want remove \"user:1\"", e.Type, e.Key)
+	}
+
+	// Expiration detected by Get.
+	if err := fc.Set("user:2", "c", -time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	receiveEvent(t, events)
+	var got string
+	fc.Get("user:2", &got)
+	if e := receiveEvent(t, events); e.Type != EventExpire || e.Key != "user:2" {
+		t.Errorf("got %s %q; want expire \"user:2\"", e.Type, e.Key)
+	}
+
+	// Corruption detected by Get.
+	if err := fc.Set("user:3", "d", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	receiveEvent(t, events)
+	if err := os.WriteFile(fc.filePath("user:3"), []byte("garbage"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	fc.Get("user:3", &got)
+	if e := receiveEvent(t, events); e.Type != EventCorrupt || e.Key != "user:3" {
+		t.Errorf("got %s %q; want corrupt \"user:3\"", e.Type, e.Key)
+	}
+
+	// Eviction: "other" expires first and is evicted once the cache is full.
+	if err := fc.Set("user:4", "e", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("user:5", "f", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("user:6", "g", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	for _, want := range []string{"user:4", "user:5", "user:6"} {
+		if e := receiveEvent(t, events); e.Type != EventSet || e.Key != want {
+			t.Errorf("got %s %q; want set %q", e.Type, e.Key, want)
+		}
+	}
+	if _, err := fc.Get("other", &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if fc.Size() > 3 {
+		t.Errorf("Size = %d; want <= 3", fc.Size())
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel not closed after cancel")
+	}
+	cancel() // must be safe to call twice
+}
+
+func TestFileCache_WatchEvict(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithMaxItems(2))
+	defer cleanup()
+
+	events, cancel := fc.Watch("")
+	defer cancel()
+
+	if err := fc.Set("short", "a", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("long", "b", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := fc.Set("new", "c", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	want := []struct {
+		eventType EventType
+		key       string
+	}{
+		{EventSet, "short"},
+		{EventSet, "long"},
+		{EventEvict, "short"},
+		{EventSet, "new"},
+	}
+	for _, w := range want {
+		if e := receiveEvent(t, events); e.Type != w.eventType || e.Key != w.key {
+			t.Errorf("got %s %q; want %s %q", e.Type, e.Key, w.eventType, w.key)
+		}
+	}
+}
+
+func TestFileCache_WatchDropsForSlowConsumer(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithWatchBuffer(2))
+	defer cleanup()
+
+	events, cancel := fc.Watch("")
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		if err := fc.Set("key", i, time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	// The two buffered events are delivered; the other three are dropped.
+	for i := 0; i < 2; i++ {
+		if e := receiveEvent(t, events); e.Dropped != 0 {
+			t.Errorf("event %d: Dropped = %d; want 0", i, e.Dropped)
+		}
+	}
+	expectNoEvent(t, events)
+
+	if err := fc.Remove("key"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if e := receiveEvent(t, events); e.Type != EventRemove || e.Dropped != 3 {
+		t.Errorf("got %s with Dropped = %d; want remove with Dropped = 3", e.Type, e.Dropped)
+	}
+}
+
+func TestFileCache_WatchOrderMatchesWrites(t *testing.T) {
+	fc, cleanup := setupTestCache(t, WithWatchBuffer(1024), WithDurability(DurabilityNone))
+	defer cleanup()
+
+	events, cancel := fc.Watch("")
+	defer cancel()
+
+	for i := 0; i < 200; i++ {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			fc.Set("key", i, time.Minute)
+		}()
+		go func() {
+			defer wg.Done()
+			fc.Remove("key")
+		}()
+		wg.Wait()
+
+		// The last event for the key must describe its final state.
+		var last Event
+		for drained := false; !drained; {
+			select {
+			case e := <-events:
+				last = e
+			default:
+				drained = true
+			}
+		}
+		var value int
+		found, _ := fc.Get("key", &value)
+		if want := map[bool]EventType{true: EventSet, false: EventRemove}[found]; last.Type != want {
+			t.Fatalf("round %d: last event %s but key found=%v", i, last.Type, found)
+		}
+		if found {
+			fc.Remove("key")
+			receiveEvent(t, events)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// AppDirectories 应用在当前用户下的标准目录，均为绝对路径且已包含应用名
+type AppDirectories struct {
+	Config  string // 配置文件
+	Cache   string // 可以随时删除的缓存
+	Data    string // 需要保留的数据
+	State   string // 运行状态，如历史记录、最近打开的文件
+	Log     string // 日志
+	Runtime string // socket、pid等运行时文件，只属于当前用户
+}
+
+// AppDirs 返回appName在当前平台上的标准目录，目录不会被创建，需要时调用MkdirAll
+// 查找顺序：<APPNAME>_CONFIG_DIR等应用专用的环境变量，XDG_CONFIG_HOME等XDG环境变量（所有平台），平台默认位置：
+//   - linux等: ~/.config、~/.cache、~/.local/share、~/.local/state，Log位于State下，Runtime为$XDG_RUNTIME_DIR
+//   - darwin: ~/Library/Application Support、~/Library/Caches、~/Library/Logs
+//   - windows: %APPDATA%（Config），%LOCALAPPDATA%（其余）
+//
+// 没有XDG_RUNTIME_DIR时Runtime位于临时目录下，并以用户id区分
+func AppDirs(appName string) (*AppDirectories, error) {
+	return appDirsFor(runtime.GOOS, appName, os.Getenv)
+}
+
+// appDirsFor 按指定平台和环境变量计算标准目录，便于测试其他平台
+func appDirsFor(goos, appName string, getenv func(string) string) (*AppDirectories, error) {
+	if strings.TrimSpace(appName) == "" || strings.ContainsAny(appName, `/\`) || appName == "." || appName == ".." {
+		return nil, errors.New("invalid app name: " + strconv.Quote(appName))
+	}
+
+	home := getenv("HOME")
+	if goos == "windows" && home == "" {
+		home = getenv("USERPROFILE")
+	}
+	if home == "" && goos != "windows" {
+		return nil, errors.New("$HOME is not defined")
+	}
+
+	var base AppDirectories
+	switch goos {
+	case "windows":
+		roaming, local := getenv("APPDATA"), getenv("LOCALAPPDATA")
+		if roaming == "" || local == "" {
+			if home == "" {
+				return nil, errors.New("%APPDATA% and %USERPROFILE% are not defined")
+			}
+			roaming = filepath.Join(home, "AppData", "Roaming")
+			local = filepath.Join(home, "AppData", "Local")
+		}
+		base = AppDirectories{
+			Config:  filepath.Join(roaming, appName),
+			Cache:   filepath.Join(local, appName, "Cache"),
+			Data:    filepath.Join(local, appName, "Data"),
+			State:   filepath.Join(local, appName, "State"),
+			Log:     filepath.Join(local, appName, "Logs"),
+			Runtime: filepath.Join(tempDirFor(goos, getenv), appName),
+		}
+	case "darwin", "ios":
+		support := filepath.Join(home, "Library", "Application Support", appName)
+		base = AppDirectories{
+			Config:  support,
+			Cache:   filepath.Join(home, "Library", "Caches", appName),
+			Data:    support,
+			State:   filepath.Join(support, "State"),
+			Log:     filepath.Join(home, "Library", "Logs", appName),
+			Runtime: filepath.Join(tempDirFor(goos, getenv), appName),
+		}
+	default:
+		state := filepath.Join(home, ".local", "state", appName)
+		base = AppDirectories{
+			Config:  filepath.Join(home, ".config", appName),
+			Cache:   filepath.Join(home, ".cache", appName),
+			Data:    filepath.Join(home, ".local", "share", appName),
+			State:   state,
+			Log:     filepath.Join(state, "log"),
+			Runtime: filepath.Join(tempDirFor(goos, getenv), appName+"-"+strconv.Itoa(os.Getuid())),
+		}
+	}
+
+	// XDG变量指向的是所有应用共用的目录，需要加上应用名
+	xdg := func(name, fallback string) string {
+		if dir := getenv(name); filepath.IsAbs(dir) {
+			return filepath.Join(dir, appName)
+		}
+		return fallback
+	}
+	base.Config = xdg("XDG_CONFIG_HOME", base.Config)
+	base.Cache = xdg("XDG_CACHE_HOME", base.Cache)
+	base.Data = xdg("XDG_DATA_HOME", base.Data)
+	if state := xdg("XDG_STATE_HOME", ""); state != "" {
+		base.State = state
+		if goos != "windows" && goos != "darwin" && goos != "ios" {
+			base.Log = filepath.Join(state, "log")
+		}
+	}
+	base.Runtime = xdg("XDG_RUNTIME_DIR", base.Runtime)
+
+	// 应用专用的变量直接指定最终目录
+	prefix := appEnvPrefix(appName)
+	override := func(kind string, fallback string) string {
+		if dir := getenv(prefix + "_" + kind + "_DIR"); dir != "" {
+			return filepath.Clean(dir)
+		}
+		return fallback
+	}
+	base.Config = override("CONFIG", base.Config)
+	base.Cache = override("CACHE", base.Cache)
+	base.Data = override("DATA", base.Data)
+	base.State = override("STATE", base.State)
+	base.Log = override("LOG", base.Log)
+	base.Runtime = override("RUNTIME", base.Runtime)
+	return &base, nil
+}
+
+// tempDirFor 与os.TempDir相同，但使用传入的环境变量
+func tempDirFor(goos string, getenv func(string) string) string {
+	if goos == "windows" {
+		for _, name := range []string{"TMP", "TEMP", "USERPROFILE"} {
+			if dir := getenv(name); dir != "" {
+				return dir
+			}
+		}
+		return `C:\Windows\Temp`
+	}
+	if dir := getenv("TMPDIR"); dir != "" {
+		return dir
+	}
+	return "/tmp"
+}
+
+// appEnvPrefix 应用名对应的环境变量前缀，如"my-app"为"MY_APP"
+func appEnvPrefix(appName string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, appName)
+}
+
+// MkdirAll 创建所有目录，Runtime目录只允许当前用户访问，
+// 已存在的Runtime目录是符号链接、属于其他用户或对其他用户开放时返回错误
+func (d *AppDirectories) MkdirAll() error {
+	for _, dir := range []string{d.Config, d.Cache, d.Data, d.State, d.Log} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(d.Runtime, 0700); err != nil {
+		return err
+	}
+	return checkRuntimeDir(d.Runtime)
+}
+
+// checkRuntimeDir 确认Runtime目录是真实目录且只有当前用户可以访问，
+// 临时目录下的默认位置可以被其他用户预先创建
+func checkRuntimeDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("runtime dir is not a directory")}
+	}
+	return checkRuntimeDirOwner(dir, info)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fanpath
+
+import "os"
+
+// checkRuntimeDirOwner 非unix平台的权限位不表示访问控制，不做检查
+func checkRuntimeDirOwner(dir string, info os.FileInfo) error {
+	return nil
+}
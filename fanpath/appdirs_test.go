@@ -0,0 +1,158 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAppDirsPlatformDefaults(t *testing.T) {
+	env := map[string]string{
+		"HOME":         "/home/u",
+		"USERPROFILE":  `C:\Users\u`,
+		"APPDATA":      `C:\Users\u\AppData\Roaming`,
+		"LOCALAPPDATA": `C:\Users\u\AppData\Local`,
+		"TMPDIR":       "/tmp/x",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	linux, err := appDirsFor("linux", "demo", getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linux.Config != filepath.Join("/home/u", ".config", "demo") ||
+		linux.Cache != filepath.Join("/home/u", ".cache", "demo") ||
+		linux.Data != filepath.Join("/home/u", ".local", "share", "demo") ||
+		linux.Log != filepath.Join("/home/u", ".local", "state", "demo", "log") {
+		t.Errorf("linux dirs = %+v", linux)
+	}
+
+	darwin, err := appDirsFor("darwin", "demo", getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if darwin.Cache != filepath.Join("/home/u", "Library", "Caches", "demo") ||
+		darwin.Log != filepath.Join("/home/u", "Library", "Logs", "demo") ||
+		darwin.Runtime != filepath.Join("/tmp/x", "demo") {
+		t.Errorf("darwin dirs = %+v", darwin)
+	}
+
+	windows, err := appDirsFor("windows", "demo", getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if windows.Config != filepath.Join(env["APPDATA"], "demo") ||
+		windows.Cache != filepath.Join(env["LOCALAPPDATA"], "demo", "Cache") {
+		t.Errorf("windows dirs = %+v", windows)
+	}
+}
+
+func TestAppDirsOverrides(t *testing.T) {
+	env := map[string]string{
+		"HOME":             "/home/u",
+		"XDG_CONFIG_HOME":  "/xdg/config",
+		"XDG_STATE_HOME":   "/xdg/state",
+		"XDG_RUNTIME_DIR":  "/run/user/1000",
+		"XDG_CACHE_HOME":   "relative/ignored",
+		"MY_APP_CACHE_DIR": "/custom/cache/",
+	}
+	dirs, err := appDirsFor("linux", "my-app", func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AppDirectories{
+		Config:  filepath.Join("/xdg/config", "my-app"),
+		Cache:   filepath.Clean("/custom/cache"),
+		Data:    filepath.Join("/home/u", ".local", "share", "my-app"),
+		State:   filepath.Join("/xdg/state", "my-app"),
+		Log:     filepath.Join("/xdg/state", "my-app", "log"),
+		Runtime: filepath.Join("/run/user/1000", "my-app"),
+	}
+	if *dirs != want {
+		t.Errorf("dirs = %+v\nwant %+v", *dirs, want)
+	}
+
+	for _, name := range []string{"", "a/b", ".."} {
+		if _, err := appDirsFor("linux", name, func(string) string { return "/home/u" }); err == nil {
+			t.Errorf("appDirsFor(%q) should fail", name)
+		}
+	}
+}
+
+func TestAppDirsMkdirAll(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("FANKIT_TEST_CONFIG_DIR", filepath.Join(root, "config"))
+	t.Setenv("FANKIT_TEST_CACHE_DIR", filepath.Join(root, "cache"))
+	t.Setenv("FANKIT_TEST_DATA_DIR", filepath.Join(root, "data"))
+	t.Setenv("FANKIT_TEST_STATE_DIR", filepath.Join(root, "state"))
+	t.Setenv("FANKIT_TEST_LOG_DIR", filepath.Join(root, "log"))
+	t.Setenv("FANKIT_TEST_RUNTIME_DIR", filepath.Join(root, "run"))
+
+	dirs, err := AppDirs("fankit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.MkdirAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{dirs.Config, dirs.Cache, dirs.Data, dirs.State, dirs.Log, dirs.Runtime} {
+		if !ExistDir(dir) {
+			t.Errorf("%s was not created", dir)
+		}
+	}
+}
+
+func TestAppDirsMkdirAllRejectsUnsafeRuntime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runtime dir permissions are not checked on windows")
+	}
+	root := t.TempDir()
+	t.Setenv("FANKIT_TEST_CONFIG_DIR", filepath.Join(root, "config"))
+	t.Setenv("FANKIT_TEST_CACHE_DIR", filepath.Join(root, "cache"))
+	t.Setenv("FANKIT_TEST_DATA_DIR", filepath.Join(root, "data"))
+	t.Setenv("FANKIT_TEST_STATE_DIR", filepath.Join(root, "state"))
+	t.Setenv("FANKIT_TEST_LOG_DIR", filepath.Join(root, "log"))
+
+	// A directory another user could have created ahead of time.
+	open := filepath.Join(root, "open")
+	if err := os.Mkdir(open, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(open, 0777); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FANKIT_TEST_RUNTIME_DIR", open)
+	dirs, err := AppDirs("fankit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.MkdirAll(); err == nil {
+		t.Error("MkdirAll accepted a runtime dir with mode 0777")
+	}
+
+	// A symlink to a private directory is rejected as well.
+	private := filepath.Join(root, "private")
+	if err := os.Mkdir(private, 0700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FANKIT_TEST_RUNTIME_DIR", link)
+	if dirs, err = AppDirs("fankit-test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.MkdirAll(); err == nil {
+		t.Error("MkdirAll accepted a symlinked runtime dir")
+	}
+
+	t.Setenv("FANKIT_TEST_RUNTIME_DIR", private)
+	if dirs, err = AppDirs("fankit-test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.MkdirAll(); err != nil {
+		t.Errorf("MkdirAll rejected a private runtime dir: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// checkRuntimeDirOwner Runtime目录必须属于当前用户，且不能对组和其他用户开放
+func checkRuntimeDirOwner(dir string, info os.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("runtime dir is owned by another user")}
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("runtime dir is accessible by other users")}
+	}
+	return nil
+}
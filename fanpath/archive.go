@@ -0,0 +1,549 @@
+package fanpath
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrUnsafeArchivePath 压缩包中的路径或符号链接会写到解压目录之外
+var ErrUnsafeArchivePath = errors.New("unsafe path in archive")
+
+// ErrUnknownArchive 无法识别的压缩包格式
+var ErrUnknownArchive = errors.New("unknown archive format")
+
+// reproducibleModTime Reproducible且没有指定ModTime时使用的时间，zip格式不能表示更早的时间
+var reproducibleModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveOptions 打包目录的选项，过滤规则同CopyOptions
+type ArchiveOptions struct {
+	Include        []string         // glob规则，非空时只打包匹配的文件
+	Exclude        []string         // glob规则，匹配的文件和目录都被跳过
+	IncludeRegexp  []*regexp.Regexp // 正则规则，匹配相对路径
+	ExcludeRegexp  []*regexp.Regexp // 正则规则，匹配相对路径
+	FollowSymlinks bool             // 打包符号链接指向的文件，默认把符号链接本身存入压缩包
+	Reproducible   bool             // 生成可复现的压缩包：固定修改时间，不记录属主信息
+	ModTime        time.Time        // Reproducible时所有条目使用的修改时间，零值为1980-01-01
+}
+
+// archiveEntry 待打包的一项
+type archiveEntry struct {
+	rel    string // 以/分隔的相对路径
+	path   string // 磁盘上的路径
+	info   fs.FileInfo
+	target string // 符号链接的内容
+}
+
+// listArchiveEntries 按路径顺序列出srcDir下需要打包的条目
+func listArchiveEntries(srcDir string, opts ArchiveOptions) ([]archiveEntry, error) {
+	filter, err := newCopyFilter(CopyOptions{
+		Include:       opts.Include,
+		Exclude:       opts.Exclude,
+		IncludeRegexp: opts.IncludeRegexp,
+		ExcludeRegexp: opts.ExcludeRegexp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []archiveEntry
+	err = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if filter.excluded(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !filter.included(rel) {
+			return nil
+		}
+
+		entry := archiveEntry{rel: rel, path: p}
+		if d.Type()&fs.ModeSymlink != 0 && !opts.FollowSymlinks {
+			if entry.target, err = os.Readlink(p); err != nil {
+				return err
+			}
+			entry.info, err = d.Info()
+		} else {
+			entry.info, err = os.Stat(p)
+		}
+		if err != nil {
+			return err
+		}
+		// 跟随后是目录的符号链接不展开，避免成环
+		if d.Type()&fs.ModeSymlink != 0 && entry.info.IsDir() {
+			return nil
+		}
+		if entry.info.Mode().IsRegular() || entry.info.IsDir() || entry.target != "" {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// modTime 条目在压缩包中的修改时间
+func (o ArchiveOptions) modTime(info fs.FileInfo) time.Time {
+	if !o.Reproducible {
+		return info.ModTime()
+	}
+	if o.ModTime.IsZero() {
+		return reproducibleModTime
+	}
+	return o.ModTime
+}
+
+// ZipDir 把srcDir打包为zip文件，条目按路径排序，压缩包通过AtomicWriter写入
+func ZipDir(srcDir, dstFile string, opts ArchiveOptions) error {
+	entries, err := listArchiveEntries(srcDir, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewAtomicWriter(dstFile, 0644)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		header, err := zip.FileInfoHeader(entry.info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.rel
+		header.Modified = opts.modTime(entry.info)
+		if entry.info.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+		} else if entry.target == "" {
+			header.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.target != "":
+			_, err = io.WriteString(fw, filepath.ToSlash(entry.target))
+		case entry.info.Mode().IsRegular():
+			err = copyFileTo(fw, entry.path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.rel, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// TarGzDir 把srcDir打包为tar.gz文件，条目按路径排序，压缩包通过AtomicWriter写入
+func TarGzDir(srcDir, dstFile string, opts ArchiveOptions) error {
+	entries, err := listArchiveEntries(srcDir, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewAtomicWriter(dstFile, 0644)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		header, err := tar.FileInfoHeader(entry.info, filepath.ToSlash(entry.target))
+		if err != nil {
+			return err
+		}
+		header.Name = entry.rel
+		if entry.info.IsDir() {
+			header.Name += "/"
+		}
+		header.ModTime = opts.modTime(entry.info)
+		header.Format = tar.FormatPAX
+		if opts.Reproducible {
+			header.Uid, header.Gid = 0, 0
+			header.Uname, header.Gname = "", ""
+			header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.target == "" && entry.info.Mode().IsRegular() {
+			if err := copyFileTo(tw, entry.path); err != nil {
+				return fmt.Errorf("%s: %w", entry.rel, err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// copyFileTo 把文件内容写入w
+func copyFileTo(w io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// ExtractOptions 解压的选项，过滤规则同CopyOptions，按条目以/分隔的相对路径匹配
+type ExtractOptions struct {
+	Include       []string         // glob规则，非空时只解压匹配的文件
+	Exclude       []string         // glob规则，匹配的文件和目录（连同其中的条目）都被跳过
+	IncludeRegexp []*regexp.Regexp // 正则规则，匹配相对路径
+	ExcludeRegexp []*regexp.Regexp // 正则规则，匹配相对路径
+}
+
+// Extract 解压zip、tar.gz或tar文件到dstDir，格式根据文件内容识别
+// 路径会写到dstDir之外的条目、指向dstDir之外的符号链接（包括经过已解压的符号链接后指向之外的）
+// 以及需要经过符号链接写入的条目都会被拒绝；文件和目录的权限、修改时间会被保留
+func Extract(archive, dstDir string) error {
+	return ExtractWithOptions(archive, dstDir, ExtractOptions{})
+}
+
+// ExtractWithOptions 按过滤规则解压，其余同Extract
+func ExtractWithOptions(archive, dstDir string, opts ExtractOptions) error {
+	filter, err := newCopyFilter(CopyOptions{
+		Include:       opts.Include,
+		Exclude:       opts.Exclude,
+		IncludeRegexp: opts.IncludeRegexp,
+		ExcludeRegexp: opts.ExcludeRegexp,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dstAbs, err := filepath.Abs(dstDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dstAbs, os.ModePerm); err != nil {
+		return err
+	}
+	x := &extractor{root: dstAbs, filter: filter}
+
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		info, statErr := file.Stat()
+		if statErr != nil {
+			return statErr
+		}
+		err = x.extractZip(file, info.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, gzErr := gzip.NewReader(br)
+		if gzErr != nil {
+			return gzErr
+		}
+		err = x.extractTar(tar.NewReader(gr))
+	case len(magic) >= 262 && string(magic[257:262]) == "ustar":
+		err = x.extractTar(tar.NewReader(br))
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownArchive, archive)
+	}
+	if err != nil {
+		return err
+	}
+	if err := x.checkLinks(); err != nil {
+		return err
+	}
+	return x.finishDirs()
+}
+
+// extractor 解压到root目录
+type extractor struct {
+	root   string
+	filter *copyFilter
+	dirs   []pendingDir      // 等待恢复权限和修改时间的目录
+	links  map[string]string // 已创建的符号链接，以/分隔的相对路径到链接内容
+}
+
+// skip 条目是否被过滤规则排除，所在的任意一级目录被排除时条目也被排除
+func (x *extractor) skip(name string, isDir bool) bool {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	for dir := path.Dir(clean); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if x.filter.excluded(dir, true) {
+			return true
+		}
+	}
+	if x.filter.excluded(clean, isDir) {
+		return true
+	}
+	return !isDir && !x.filter.included(clean)
+}
+
+func (x *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	// 不安全的路径由target统一检查并返回ErrUnsafeArchivePath
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if x.skip(f.Name, mode.IsDir()) {
+			continue
+		}
+		switch {
+		case mode.IsDir():
+			err = x.mkdir(f.Name, mode.Perm(), f.Modified)
+		case mode&fs.ModeSymlink != 0:
+			err = x.extractZipSymlink(f)
+		case mode.IsRegular():
+			err = x.extractZipFile(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractZipFile(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return x.writeFile(f.Name, rc, f.Mode().Perm(), f.Modified)
+}
+
+func (x *extractor) extractZipSymlink(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return x.symlink(f.Name, string(target))
+}
+
+func (x *extractor) extractTar(tr *tar.Reader) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if x.skip(header.Name, header.Typeflag == tar.TypeDir) {
+			continue
+		}
+		mode := fs.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(header.Name, mode, header.ModTime)
+		case tar.TypeReg:
+			err = x.writeFile(header.Name, tr, mode, header.ModTime)
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		default:
+			// 硬链接、设备文件等不解压
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// target 检查条目名称并返回解压后的路径
+// 拒绝绝对路径、盘符、跳出root的..，以及父目录中存在符号链接的情况
+func (x *extractor) target(name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, ":") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeArchivePath, name)
+	}
+
+	current := x.root
+	parts := strings.Split(clean, "/")
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s passes through symlink", ErrUnsafeArchivePath, name)
+		}
+	}
+	return filepath.Join(x.root, filepath.FromSlash(clean)), nil
+}
+
+func (x *extractor) mkdir(name string, perm fs.FileMode, modTime time.Time) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("%w: %s is a symlink", ErrUnsafeArchivePath, name)
+	}
+	if err := os.MkdirAll(target, 0700|perm); err != nil {
+		return err
+	}
+	x.dirs = append(x.dirs, pendingDir{path: target, rel: name, info: archiveDirInfo{perm: perm, modTime: modTime}})
+	return nil
+}
+
+func (x *extractor) writeFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	w, err := NewAtomicWriter(target, perm)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	if err := w.Commit(); err != nil {
+		return err
+	}
+	if modTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(target, modTime, modTime)
+}
+
+// symlink 创建符号链接，链接内容必须是相对路径，并且经过已解压的符号链接解析后仍在root之内
+func (x *extractor) symlink(name, linkTarget string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	slashed := strings.ReplaceAll(linkTarget, `\`, "/")
+	if linkTarget == "" || path.IsAbs(slashed) || filepath.IsAbs(linkTarget) || strings.Contains(slashed, ":") {
+		return fmt.Errorf("%w: symlink %s -> %s", ErrUnsafeArchivePath, name, linkTarget)
+	}
+	rel := filepath.ToSlash(strings.TrimPrefix(target, x.root+string(filepath.Separator)))
+	if err := x.checkLink(rel, slashed); err != nil {
+		return err
+	}
+
+	if err := CreateDirIfNoExist(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Symlink(filepath.FromSlash(slashed), target); err != nil {
+		return err
+	}
+	if x.links == nil {
+		x.links = make(map[string]string)
+	}
+	x.links[rel] = slashed
+	return nil
+}
+
+// checkLink 检查rel处内容为linkTarget的符号链接是否指向root之内，路径中已存在的符号链接会被逐级解析
+// 不按字面清理路径，因此"s/.."在s是符号链接时按s指向的位置计算
+func (x *extractor) checkLink(rel, linkTarget string) error {
+	if _, err := secureJoin(x.root, path.Dir(rel)+"/"+linkTarget, true); err != nil {
+		return fmt.Errorf("%w: symlink %s -> %s", ErrUnsafeArchivePath, rel, linkTarget)
+	}
+	return nil
+}
+
+// checkLinks 解压结束后重新检查所有符号链接，后创建的链接可能改变先创建的链接的指向
+// 指向root之外的链接会被删除
+func (x *extractor) checkLinks() error {
+	var errs []error
+	for rel, linkTarget := range x.links {
+		linkPath := filepath.Join(x.root, filepath.FromSlash(rel))
+		if info, err := os.Lstat(linkPath); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			// 已经被之后的条目替换
+			continue
+		}
+		if err := x.checkLink(rel, linkTarget); err != nil {
+			os.Remove(linkPath)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// finishDirs 恢复目录的权限和修改时间，子目录先于父目录处理
+func (x *extractor) finishDirs() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		dir := x.dirs[i]
+		// 目录之后被同名的符号链接替换时不再修改，避免经过链接修改root之外的文件
+		if info, err := os.Lstat(dir.path); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.Chmod(dir.path, dir.info.Mode().Perm()); err != nil {
+			return err
+		}
+		if modTime := dir.info.ModTime(); !modTime.IsZero() {
+			if err := os.Chtimes(dir.path, modTime, modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archiveDirInfo 压缩包中目录条目的权限和修改时间，实现fs.FileInfo以复用pendingDir
+type archiveDirInfo struct {
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+func (i archiveDirInfo) Name() string       { return "" }
+func (i archiveDirInfo) Size() int64        { return 0 }
+func (i archiveDirInfo) Mode() fs.FileMode  { return fs.ModeDir | i.perm }
+func (i archiveDirInfo) ModTime() time.Time { return i.modTime }
+func (i archiveDirInfo) IsDir() bool        { return true }
+func (i archiveDirInfo) Sys() any           { return nil }
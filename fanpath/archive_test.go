@@ -0,0 +1,211 @@
+package fanpath
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src, "bin/tool", "#!/bin/sh")
+	writeTestFile(t, src, "data/a.txt", "a")
+	writeTestFile(t, src, "data/skip.log", "log")
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chmod(filepath.Join(src, "bin", "tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(src, "data", "a.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("data/a.txt", filepath.Join(src, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archivers := map[string]func(src, dst string, opts ArchiveOptions) error{
+		"out.zip":    ZipDir,
+		"out.tar.gz": TarGzDir,
+	}
+	for name, archive := range archivers {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), name)
+			if err := archive(src, file, ArchiveOptions{Exclude: []string{"*.log"}}); err != nil {
+				t.Fatalf("archive failed: %v", err)
+			}
+
+			dst := t.TempDir()
+			if err := Extract(file, dst); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if got := readTestFile(t, dst, "data/a.txt"); got != "a" {
+				t.Errorf("data/a.txt = %q", got)
+			}
+			if ExistPath(filepath.Join(dst, "data", "skip.log")) {
+				t.Error("excluded file was archived")
+			}
+			info, err := os.Stat(filepath.Join(dst, "data", "a.txt"))
+			if err != nil || !info.ModTime().Equal(mtime) {
+				t.Errorf("mtime = %v, %v; want %v", info.ModTime(), err, mtime)
+			}
+			if runtime.GOOS == "windows" {
+				return
+			}
+			if info, err := os.Stat(filepath.Join(dst, "bin", "tool")); err != nil || info.Mode().Perm() != 0755 {
+				t.Errorf("bin/tool mode = %v, %v; want 0755", info.Mode().Perm(), err)
+			}
+			if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "data/a.txt" {
+				t.Errorf("link = %q, %v", target, err)
+			}
+		})
+	}
+}
+
+func TestArchiveReproducible(t *testing.T) {
+	build := func(mtime time.Time) string {
+		dir := t.TempDir()
+		for _, rel := range []string{"b.txt", "a/c.txt", "a.txt"} {
+			path := writeTestFile(t, dir, rel, rel)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	first := build(time.Now().Add(-time.Hour))
+	second := build(time.Now())
+
+	for _, archive := range []func(src, dst string, opts ArchiveOptions) error{ZipDir, TarGzDir} {
+		out := t.TempDir()
+		a, b := filepath.Join(out, "a"), filepath.Join(out, "b")
+		opts := ArchiveOptions{Reproducible: true}
+		if err := archive(first, a, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := archive(second, b, opts); err != nil {
+			t.Fatal(err)
+		}
+		dataA, _ := os.ReadFile(a)
+		dataB, _ := os.ReadFile(b)
+		if !bytes.Equal(dataA, dataB) {
+			t.Errorf("reproducible archives differ (%d vs %d bytes)", len(dataA), len(dataB))
+		}
+	}
+}
+
+// writeTarGz builds a tar.gz archive from raw headers for the traversal tests.
+func writeTarGz(t *testing.T, headers []*tar.Header) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, h := range headers {
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(h.Name))
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte(h.Name))
+		}
+	}
+	tw.Close()
+	gw.Close()
+	path := filepath.Join(t.TempDir(), "evil.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	cases := map[string][]*tar.Header{
+		"dotdot":          {{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644}},
+		"absolute":        {{Name: "/tmp/evil.txt", Typeflag: tar.TypeReg, Mode: 0644}},
+		"absolute link":   {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		"escaping link":   {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../outside"}},
+		"through symlink": {{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "d/up", Typeflag: tar.TypeSymlink, Linkname: ".."}, {Name: "d/up/up2", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+		"chained link":    {{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "d/s", Typeflag: tar.TypeSymlink, Linkname: ".."}, {Name: "d/t", Typeflag: tar.TypeSymlink, Linkname: "s/.."}},
+		"chained reverse": {{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "d/t", Typeflag: tar.TypeSymlink, Linkname: "s/.."}, {Name: "d/s", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+	}
+	for name, headers := range cases {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			err := Extract(writeTarGz(t, headers), dst)
+			if !errors.Is(err, ErrUnsafeArchivePath) {
+				t.Fatalf("Extract = %v; want ErrUnsafeArchivePath", err)
+			}
+			entries, _ := os.ReadDir(parent)
+			if len(entries) != 1 {
+				t.Errorf("files written outside the destination: %v", entries)
+			}
+			// No link that resolves outside the destination may be left behind.
+			filepath.WalkDir(dst, func(p string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type()&fs.ModeSymlink != 0 {
+					if real, err := filepath.EvalSymlinks(p); err == nil && !IsWithin(dst, real) {
+						t.Errorf("%s resolves outside the destination to %s", p, real)
+					}
+				}
+				return nil
+			})
+		})
+	}
+
+	// zip entries are checked the same way.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, _ := zw.Create("a/../../evil.txt")
+	fw.Write([]byte("x"))
+	zw.Close()
+	archive := filepath.Join(t.TempDir(), "evil.zip")
+	os.WriteFile(archive, buf.Bytes(), 0644)
+	if err := Extract(archive, t.TempDir()); !errors.Is(err, ErrUnsafeArchivePath) {
+		t.Errorf("zip Extract = %v; want ErrUnsafeArchivePath", err)
+	}
+
+	if err := Extract(writeTestFile(t, t.TempDir(), "plain.txt", "not an archive"), t.TempDir()); !errors.Is(err, ErrUnknownArchive) {
+		t.Errorf("Extract plain file = %v; want ErrUnknownArchive", err)
+	}
+}
+
+func TestExtractWithFilters(t *testing.T) {
+	archive := writeTarGz(t, []*tar.Header{
+		{Name: "keep.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "skip.log", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "cache/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "cache/data.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "sub/nested.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	})
+	dst := t.TempDir()
+	opts := ExtractOptions{Include: []string{"*.txt"}, Exclude: []string{"cache"}}
+	if err := ExtractWithOptions(archive, dst, opts); err != nil {
+		t.Fatalf("ExtractWithOptions failed: %v", err)
+	}
+
+	var got []string
+	filepath.WalkDir(dst, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dst, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if want := []string{"keep.txt", "sub/nested.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extracted %v; want %v", got, want)
+	}
+	if ExistPath(filepath.Join(dst, "cache")) {
+		t.Error("excluded directory was created")
+	}
+}
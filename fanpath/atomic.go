@@ -0,0 +1,110 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrWriterClosed AtomicWriter已经提交或放弃后再次写入或提交
+var ErrWriterClosed = errors.New("atomic writer already committed or aborted")
+
+// AtomicWriter 原子地写入文件：内容先写入同目录下的临时文件，Commit时fsync并重命名覆盖目标文件，
+// 再对父目录fsync，因此目标路径上只会出现完整的旧文件或完整的新文件
+// 没有Commit就Close等同于Abort，所以可以放心地defer Close
+type AtomicWriter struct {
+	file *os.File
+	path string
+	done bool
+}
+
+// NewAtomicWriter 在path所在目录创建临时文件，父目录不存在时会创建
+// perm为提交后文件的权限，不受umask影响
+func NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if err := CreateDirIfNoExist(dir); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &AtomicWriter{file: file, path: path}, nil
+}
+
+// Name 目标文件路径
+func (w *AtomicWriter) Name() string {
+	return w.path
+}
+
+// Write 写入临时文件
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, ErrWriterClosed
+	}
+	return w.file.Write(p)
+}
+
+// Commit 把临时文件落盘并重命名为目标文件，失败时删除临时文件
+func (w *AtomicWriter) Commit() error {
+	if w.done {
+		return ErrWriterClosed
+	}
+	w.done = true
+
+	tmpPath := w.file.Name()
+	err := w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return FsyncDir(filepath.Dir(w.path))
+}
+
+// Abort 放弃写入并删除临时文件，目标文件保持不变；已经提交或放弃时不做任何事
+func (w *AtomicWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	err := w.file.Close()
+	if removeErr := os.Remove(w.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Close 实现io.Closer，没有提交时放弃写入
+func (w *AtomicWriter) Close() error {
+	return w.Abort()
+}
+
+// WriteFileAtomic 原子地写入文件，与os.WriteFile相同的用法，但不会留下只写了一部分的文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	w, err := NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Commit()
+}
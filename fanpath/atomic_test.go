@@ -0,0 +1,91 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tempFiles lists leftover temporary files in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "config.json")
+
+	if err := WriteFileAtomic(path, []byte("v1"), 0640); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("v2"), 0640); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if got := readTestFile(t, dir, "sub/config.json"); got != "v2" {
+		t.Errorf("content = %q; want \"v2\"", got)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0640) {
+		t.Errorf("mode = %v, %v; want 0640", info.Mode().Perm(), err)
+	}
+	if left := tempFiles(t, filepath.Dir(path)); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
+
+func TestAtomicWriterAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "data.txt", "original")
+
+	w, err := NewAtomicWriter(path, 0644)
+	if err != nil {
+		t.Fatalf("NewAtomicWriter failed: %v", err)
+	}
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	// Closing without Commit discards the new content.
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := readTestFile(t, dir, "data.txt"); got != "original" {
+		t.Errorf("content = %q; want \"original\"", got)
+	}
+	if left := tempFiles(t, dir); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Write after Close: err = %v; want ErrWriterClosed", err)
+	}
+	if err := w.Commit(); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Commit after Close: err = %v; want ErrWriterClosed", err)
+	}
+}
+
+func TestAtomicWriterCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.bin")
+
+	w, err := NewAtomicWriter(path, 0644)
+	if err != nil {
+		t.Fatalf("NewAtomicWriter failed: %v", err)
+	}
+	defer w.Close()
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+	if ExistPath(path) {
+		t.Fatal("target visible before Commit")
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if got := readTestFile(t, dir, "out.bin"); got != "hello world" {
+		t.Errorf("content = %q", got)
+	}
+}
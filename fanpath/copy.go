@@ -0,0 +1,598 @@
+package fanpath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"syscall"
+)
+
+// SymlinkMode 拷贝目录时对符号链接的处理方式
+type SymlinkMode int
+
+const (
+	SymlinkFollow SymlinkMode = iota // 跟随符号链接，拷贝其指向的文件或目录，链接成环时报错（默认）
+	SymlinkCopy                      // 在目标目录中创建指向相同目标的符号链接，链接内容原样保留
+	SymlinkSkip                      // 跳过符号链接
+)
+
+// OverwritePolicy 目标文件已存在时的处理方式
+type OverwritePolicy int
+
+const (
+	OverwriteAlways      OverwritePolicy = iota // 总是覆盖（默认）
+	OverwriteNever                              // 从不覆盖
+	OverwriteIfNewer                            // 源文件的修改时间比目标文件新时覆盖
+	OverwriteIfDifferent                        // 源文件与目标文件内容不同时覆盖，大小相同时比较内容哈希
+	OverwriteIfChanged                          // 源文件与目标文件大小或修改时间不同时覆盖
+)
+
+// CopyOptions 拷贝目录的选项
+// 过滤规则使用的相对路径以/分隔；glob规则的语法同Glob，同时匹配相对路径和文件名，因此"*.go"可以匹配任意层级的go文件
+type CopyOptions struct {
+	Symlinks      SymlinkMode          // 符号链接的处理方式
+	Include       []string             // glob规则，非空时只拷贝匹配的文件，目录总是会被遍历
+	Exclude       []string             // glob规则，匹配的文件和目录（连同其内容）都被跳过
+	IncludeRegexp []*regexp.Regexp     // 正则规则，匹配相对路径，非空时只拷贝匹配的文件
+	ExcludeRegexp []*regexp.Regexp     // 正则规则，匹配相对路径，匹配的文件和目录都被跳过
+	Overwrite     OverwritePolicy      // 目标文件已存在时的处理方式
+	DryRun        bool                 // 只统计将要拷贝的文件，不修改目标目录
+	Progress      func(p CopyProgress) // 进度回调，每写入一段数据以及每处理完一个文件时调用，多个worker时回调也是串行的
+	Workers       int                  // 并行拷贝文件的worker数量，小于等于1时串行拷贝
+}
+
+// CopyProgress 拷贝进度
+type CopyProgress struct {
+	Path      string // 当前文件的相对路径
+	Files     int    // 已经处理完的文件数量（包括跳过和失败的文件）
+	Bytes     int64  // 已经拷贝成功的文件的总字节数，与CopySummary.Bytes一致
+	FileBytes int64  // 当前文件已经写入的字节数，文件拷贝失败时不计入Bytes
+}
+
+// CopyFailure 拷贝失败的文件
+type CopyFailure struct {
+	Path string // 相对路径
+	Err  error
+}
+
+// CopySummary 拷贝结果，路径均为相对于源目录的路径
+type CopySummary struct {
+	Copied   []string      // 已拷贝（DryRun时为将要拷贝）的文件
+	Replaced []string      // Copied中覆盖了已有目标文件的部分
+	Skipped  []string      // 被过滤规则或覆盖策略跳过的文件
+	Failed   []CopyFailure // 拷贝失败的文件和目录
+	Bytes    int64         // 拷贝成功的文件的总字节数，不包括失败的文件已经写入的部分
+}
+
+// CopyFile 拷贝文件，目标文件通过AtomicWriter写入，拷贝中断时不会留下不完整的目标文件
+func CopyFile(src, dst string) error {
+	_, err := copyFile(src, dst, nil)
+	return err
+}
+
+// copyFile 拷贝文件，onWrite在每写入一段数据后被调用，返回拷贝的字节数
+// 文件内容由copyContents拷贝，在支持的平台和文件系统上会使用reflink或内核态拷贝
+func copyFile(src, dst string, onWrite func(n int64)) (int64, error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	w, err := NewAtomicWriter(dst, srcInfo.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+
+	if onWrite == nil {
+		onWrite = func(int64) {}
+	}
+	n, err := copyContents(w.file, srcFile, srcInfo.Size(), onWrite)
+	if err != nil {
+		return n, err
+	}
+
+	return n, w.Commit()
+}
+
+// copyBufferSize 缓冲拷贝使用的缓冲区大小
+const copyBufferSize = 1 << 20
+
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+// copyBuffered 在用户态通过缓冲区拷贝文件内容
+func copyBuffered(dst, src *os.File, onWrite func(n int64)) (int64, error) {
+	buf := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(buf)
+
+	// 包装成普通的Reader和Writer，避免io.CopyBuffer走os.File的ReadFrom
+	w := &progressWriter{w: dst, onWrite: onWrite}
+	return io.CopyBuffer(w, struct{ io.Reader }{src}, *buf)
+}
+
+// progressWriter 每次写入后回调写入的字节数
+type progressWriter struct {
+	w       io.Writer
+	onWrite func(n int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	if n > 0 {
+		pw.onWrite(int64(n))
+	}
+	return n, err
+}
+
+// CopyDir 拷贝目录，保留目录结构、文件权限和修改时间，符号链接会被跟随
+func CopyDir(srcDir, dstDir string) error {
+	_, err := CopyDirWithOptions(srcDir, dstDir, CopyOptions{})
+	return err
+}
+
+// CopyDirWithOptions 按选项拷贝目录，保留目录结构、文件权限和修改时间
+// 单个文件拷贝失败不会中断整个拷贝，失败的文件记录在结果中，此时返回的error包含所有失败原因
+// 目标目录可以位于源目录之内，拷贝时会跳过目标目录自身，但不能与源目录相同
+func CopyDirWithOptions(srcDir, dstDir string, opts CopyOptions) (*CopySummary, error) {
+	filter, err := newCopyFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	srcAbs, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	dstAbs, err := filepath.Abs(dstDir)
+	if err != nil {
+		return nil, err
+	}
+
+	srcInfo, err := os.Stat(srcAbs)
+	if err != nil {
+		return nil, err
+	}
+	if !srcInfo.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", srcDir)
+	}
+
+	srcReal, err := filepath.EvalSymlinks(srcAbs)
+	if err != nil {
+		return nil, err
+	}
+	dstReal, err := filepath.EvalSymlinks(dstAbs)
+	if err == nil && dstReal == srcReal {
+		return nil, fmt.Errorf("cannot copy directory %s onto itself", srcDir)
+	}
+
+	if !opts.DryRun {
+		if err := os.MkdirAll(dstAbs, os.ModePerm); err != nil {
+			return nil, err
+		}
+		if dstReal, err = filepath.EvalSymlinks(dstAbs); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &treeCopier{
+		opts:      opts,
+		filter:    filter,
+		skipPath:  dstReal,
+		ancestors: make(map[string]bool),
+		summary:   &CopySummary{},
+	}
+	c.startWorkers()
+	c.copyDir(srcAbs, dstAbs, "", srcInfo)
+	c.wait()
+	c.finishDirs()
+
+	var errs []error
+	for _, failure := range c.summary.Failed {
+		errs = append(errs, fmt.Errorf("%s: %w", failure.Path, failure.Err))
+	}
+	return c.summary, errors.Join(errs...)
+}
+
+// copyFilter 编译后的过滤规则
+type copyFilter struct {
+	include       []globPattern
+	exclude       []globPattern
+	includeRegexp []*regexp.Regexp
+	excludeRegexp []*regexp.Regexp
+}
+
+func newCopyFilter(opts CopyOptions) (*copyFilter, error) {
+	f := &copyFilter{
+		includeRegexp: opts.IncludeRegexp,
+		excludeRegexp: opts.ExcludeRegexp,
+	}
+	for _, pattern := range opts.Include {
+		p, err := compileGlob(pattern, false)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, p)
+	}
+	for _, pattern := range opts.Exclude {
+		p, err := compileGlob(pattern, false)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, p)
+	}
+	return f, nil
+}
+
+// excluded 文件或目录是否被排除
+func (f *copyFilter) excluded(rel string, isDir bool) bool {
+	return matchGlobs(f.exclude, rel, isDir) || matchRegexps(f.excludeRegexp, rel)
+}
+
+// included 文件是否满足包含规则
+func (f *copyFilter) included(rel string) bool {
+	if len(f.include) == 0 && len(f.includeRegexp) == 0 {
+		return true
+	}
+	return matchGlobs(f.include, rel, false) || matchRegexps(f.includeRegexp, rel)
+}
+
+// matchGlobs 相对路径或文件名是否匹配任意一条glob规则
+func matchGlobs(patterns []globPattern, rel string, isDir bool) bool {
+	return matchAny(patterns, rel, isDir) || matchAny(patterns, path.Base(rel), isDir)
+}
+
+// matchRegexps 相对路径是否匹配任意一条正则规则
+func matchRegexps(regexps []*regexp.Regexp, rel string) bool {
+	for _, reg := range regexps {
+		if reg.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// treeCopier 递归拷贝目录树
+type treeCopier struct {
+	opts      CopyOptions
+	filter    *copyFilter
+	skipPath  string          // 目标目录的真实路径，当目标位于源目录之内时需要跳过
+	ancestors map[string]bool // 当前路径上所有目录的真实路径，用于检测符号链接成环
+	dirs      []pendingDir    // 等待恢复权限和修改时间的目录，按先序遍历的顺序排列
+
+	jobs chan func() // 并行拷贝时的任务队列，串行拷贝时为nil
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex // 保护summary和files，同时保证进度回调串行
+	summary *CopySummary
+	files   int
+}
+
+// pendingDir 已经创建、但还没有恢复元数据的目录
+type pendingDir struct {
+	path string
+	rel  string
+	info fs.FileInfo
+}
+
+// startWorkers 按选项启动拷贝文件的worker
+func (c *treeCopier) startWorkers() {
+	if c.opts.Workers <= 1 {
+		return
+	}
+	c.jobs = make(chan func(), c.opts.Workers)
+	for i := 0; i < c.opts.Workers; i++ {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			for job := range c.jobs {
+				job()
+			}
+		}()
+	}
+}
+
+// run 执行拷贝任务，有worker时交给worker执行，队列满时阻塞
+func (c *treeCopier) run(job func()) {
+	if c.jobs == nil {
+		job()
+		return
+	}
+	c.jobs <- job
+}
+
+// wait 等待所有拷贝任务完成
+func (c *treeCopier) wait() {
+	if c.jobs != nil {
+		close(c.jobs)
+		c.wg.Wait()
+	}
+}
+
+// finishDirs 恢复目录的权限和修改时间，子目录先于父目录处理，避免只读目录阻止子目录的修改
+func (c *treeCopier) finishDirs() {
+	for i := len(c.dirs) - 1; i >= 0; i-- {
+		dir := c.dirs[i]
+		if err := preserveFileInfo(dir.path, dir.info); err != nil {
+			c.fail(dir.rel, err)
+		}
+	}
+}
+
+// fail 记录失败
+func (c *treeCopier) fail(rel string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary.Failed = append(c.summary.Failed, CopyFailure{Path: rel, Err: err})
+}
+
+// copyDir 拷贝src目录到dst，rel为相对路径，info为src（跟随链接后）的信息
+func (c *treeCopier) copyDir(src, dst, rel string, info fs.FileInfo) {
+	srcReal, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		c.fail(rel, err)
+		return
+	}
+	if c.ancestors[srcReal] {
+		c.fail(rel, fmt.Errorf("symlink loop detected at %s", src))
+		return
+	}
+	c.ancestors[srcReal] = true
+	defer delete(c.ancestors, srcReal)
+
+	// 先以可写权限创建目录，全部内容拷贝完成后再恢复原有的权限和修改时间
+	if !c.opts.DryRun {
+		if err := os.MkdirAll(dst, 0700|info.Mode().Perm()); err != nil {
+			c.fail(rel, err)
+			return
+		}
+		c.dirs = append(c.dirs, pendingDir{path: dst, rel: rel, info: info})
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		c.fail(rel, err)
+		return
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		if filepath.Join(srcReal, entry.Name()) == c.skipPath {
+			continue
+		}
+		entryRel := path.Join(rel, entry.Name())
+		if c.filter.excluded(entryRel, entry.IsDir()) {
+			if !entry.IsDir() {
+				c.skip(entryRel)
+			}
+			continue
+		}
+		c.copyEntry(srcPath, filepath.Join(dst, entry.Name()), entryRel, entry)
+	}
+}
+
+// copyEntry 拷贝目录中的一项
+func (c *treeCopier) copyEntry(srcPath, dstPath, rel string, entry fs.DirEntry) {
+	if entry.Type()&fs.ModeSymlink != 0 {
+		c.copySymlink(srcPath, dstPath, rel)
+		return
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		c.fail(rel, err)
+		return
+	}
+	switch {
+	case info.IsDir():
+		c.copyDir(srcPath, dstPath, rel, info)
+	case info.Mode().IsRegular():
+		c.run(func() { c.copyRegular(srcPath, dstPath, rel, info) })
+	default:
+		// 设备文件、管道、套接字等特殊文件不拷贝
+	}
+}
+
+// copySymlink 按选项处理符号链接
+func (c *treeCopier) copySymlink(srcPath, dstPath, rel string) {
+	switch c.opts.Symlinks {
+	case SymlinkSkip:
+		c.skip(rel)
+	case SymlinkCopy:
+		if !c.filter.included(rel) {
+			c.skip(rel)
+			return
+		}
+		c.run(func() { c.recreateSymlink(srcPath, dstPath, rel) })
+	default:
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			c.fail(rel, err)
+			return
+		}
+		if info.IsDir() {
+			c.copyDir(srcPath, dstPath, rel, info)
+		} else if info.Mode().IsRegular() {
+			c.run(func() { c.copyRegular(srcPath, dstPath, rel, info) })
+		}
+	}
+}
+
+// recreateSymlink 在目标位置创建与源链接相同的符号链接
+// 目标已经是指向相同位置的链接时，除OverwriteAlways外都视为没有变化；OverwriteNever时不替换已有的目标
+func (c *treeCopier) recreateSymlink(srcPath, dstPath, rel string) {
+	target, err := os.Readlink(srcPath)
+	if err != nil {
+		c.finishFile(rel, 0, err)
+		return
+	}
+
+	_, err = os.Lstat(dstPath)
+	exists := err == nil
+	if exists {
+		existing, _ := os.Readlink(dstPath)
+		if c.opts.Overwrite == OverwriteNever || (existing == target && c.opts.Overwrite != OverwriteAlways) {
+			c.skip(rel)
+			return
+		}
+	}
+
+	if !c.opts.DryRun {
+		if err := os.Remove(dstPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.finishFile(rel, 0, err)
+			return
+		}
+		if err := os.Symlink(target, dstPath); err != nil {
+			c.finishFile(rel, 0, err)
+			return
+		}
+	}
+	if exists {
+		c.mu.Lock()
+		c.summary.Replaced = append(c.summary.Replaced, rel)
+		c.mu.Unlock()
+	}
+	c.finishFile(rel, 0, nil)
+}
+
+// copyRegular 按过滤规则和覆盖策略拷贝普通文件，并保留权限和修改时间
+func (c *treeCopier) copyRegular(srcPath, dstPath, rel string, info fs.FileInfo) {
+	if !c.filter.included(rel) {
+		c.skip(rel)
+		return
+	}
+
+	overwrite, exists, err := shouldOverwrite(c.opts.Overwrite, srcPath, dstPath, info)
+	if err != nil {
+		c.finishFile(rel, 0, err)
+		return
+	}
+	if !overwrite {
+		c.skip(rel)
+		return
+	}
+
+	var written int64
+	if c.opts.DryRun {
+		written = info.Size()
+	} else {
+		_, err = copyFile(srcPath, dstPath, func(n int64) {
+			written += n
+			c.reportBytes(rel, written)
+		})
+		if err == nil {
+			err = preserveFileInfo(dstPath, info)
+		}
+	}
+	if exists && err == nil {
+		c.mu.Lock()
+		c.summary.Replaced = append(c.summary.Replaced, rel)
+		c.mu.Unlock()
+	}
+	c.finishFile(rel, written, err)
+}
+
+// reportBytes 回调当前文件的写入进度，written在文件拷贝成功前不计入总字节数
+func (c *treeCopier) reportBytes(rel string, written int64) {
+	if c.opts.Progress == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.Progress(CopyProgress{Path: rel, Files: c.files, Bytes: c.summary.Bytes, FileBytes: written})
+}
+
+// skip 记录被跳过的文件
+func (c *treeCopier) skip(rel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary.Skipped = append(c.summary.Skipped, rel)
+	c.reportFile(rel, 0)
+}
+
+// finishFile 记录文件的拷贝结果，只有拷贝成功时written才计入总字节数
+func (c *treeCopier) finishFile(rel string, written int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.summary.Failed = append(c.summary.Failed, CopyFailure{Path: rel, Err: err})
+	} else {
+		c.summary.Copied = append(c.summary.Copied, rel)
+		c.summary.Bytes += written
+	}
+	c.reportFile(rel, written)
+}
+
+// reportFile 处理完一个文件后回调进度，调用方需持有c.mu
+func (c *treeCopier) reportFile(rel string, written int64) {
+	c.files++
+	if c.opts.Progress != nil {
+		c.opts.Progress(CopyProgress{Path: rel, Files: c.files, Bytes: c.summary.Bytes, FileBytes: written})
+	}
+}
+
+// preserveFileInfo 把文件的权限和修改时间设置为与info相同
+func preserveFileInfo(path string, info fs.FileInfo) error {
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+// shouldOverwrite 按覆盖策略判断是否需要把src拷贝到dst，exists表示dst是否已经存在
+func shouldOverwrite(policy OverwritePolicy, src, dst string, srcInfo fs.FileInfo) (overwrite, exists bool, err error) {
+	dstInfo, err := os.Stat(dst)
+	// 父路径是普通文件时（DryRun下文件将被目录替换）同样视为不存在
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return true, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	switch policy {
+	case OverwriteNever:
+		return false, true, nil
+	case OverwriteIfNewer:
+		return srcInfo.ModTime().After(dstInfo.ModTime()), true, nil
+	case OverwriteIfChanged:
+		return srcInfo.Size() != dstInfo.Size() || !srcInfo.ModTime().Equal(dstInfo.ModTime()), true, nil
+	case OverwriteIfDifferent:
+		if srcInfo.Size() != dstInfo.Size() {
+			return true, true, nil
+		}
+		same, err := sameFileContent(src, dst)
+		return !same, true, err
+	default:
+		return true, true, nil
+	}
+}
+
+// sameFileContent 比较两个文件的内容哈希是否相同
+func sameFileContent(a, b string) (bool, error) {
+	hashA, err := hashFileBytes(a, HashSHA256)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := hashFileBytes(b, HashSHA256)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
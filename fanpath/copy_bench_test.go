@@ -0,0 +1,83 @@
+package fanpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchFileSize is the size of every file used by the copy benchmarks.
+const benchFileSize = 16 << 20
+
+func writeBenchFile(b *testing.B, path string, size int) {
+	b.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkCopyFile compares the platform copy (reflink/copy_file_range on
+// linux) against the plain buffered copy.
+func BenchmarkCopyFile(b *testing.B) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "src")
+	writeBenchFile(b, src, benchFileSize)
+
+	copiers := map[string]func(dst, src *os.File, onWrite func(n int64)) (int64, error){
+		"native": func(dst, src *os.File, onWrite func(n int64)) (int64, error) {
+			return copyContents(dst, src, benchFileSize, onWrite)
+		},
+		"buffered": copyBuffered,
+	}
+	for _, name := range []string{"buffered", "native"} {
+		copier := copiers[name]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(benchFileSize)
+			dst := filepath.Join(dir, name)
+			for i := 0; i < b.N; i++ {
+				in, err := os.Open(src)
+				if err != nil {
+					b.Fatal(err)
+				}
+				out, err := os.Create(dst)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := copier(out, in, func(int64) {}); err != nil {
+					b.Fatal(err)
+				}
+				in.Close()
+				out.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkCopyDir measures CopyDirWithOptions with different worker counts.
+func BenchmarkCopyDir(b *testing.B) {
+	src := b.TempDir()
+	const files = 32
+	for i := 0; i < files; i++ {
+		writeBenchFile(b, filepath.Join(src, fmt.Sprintf("d%d", i%4), fmt.Sprintf("f%d", i)), benchFileSize/16)
+	}
+
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(files * benchFileSize / 16)
+			for i := 0; i < b.N; i++ {
+				dst := filepath.Join(b.TempDir(), "out")
+				if _, err := CopyDirWithOptions(src, dst, CopyOptions{Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
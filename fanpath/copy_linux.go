@@ -0,0 +1,51 @@
+package fanpath
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// ioctlFiclone FICLONE的ioctl请求码，powerpc和mips上ioctl的方向位编码不同
+var ioctlFiclone = func() uintptr {
+	switch runtime.GOARCH {
+	case "ppc64", "ppc64le", "mips", "mipsle", "mips64", "mips64le":
+		return 0x80049409
+	default:
+		return 0x40049409
+	}
+}()
+
+// copyRangeChunk 每次交给ReadFrom拷贝的最大字节数，决定进度回调的粒度
+const copyRangeChunk = 8 << 20
+
+// copyContents 拷贝文件内容，优先使用reflink共享数据块，否则交给(*os.File).ReadFrom：
+// 它会尝试copy_file_range等内核态拷贝，并处理了内核版本差异，例如旧内核对procfs返回0字节时退回用户态拷贝
+func copyContents(dst, src *os.File, size int64, onWrite func(n int64)) (int64, error) {
+	if size > 0 && reflink(dst, src) == nil {
+		onWrite(size)
+		return size, nil
+	}
+
+	var written int64
+	for {
+		n, err := dst.ReadFrom(&io.LimitedReader{R: src, N: copyRangeChunk})
+		written += n
+		if n > 0 {
+			onWrite(n)
+		}
+		if err != nil || n < copyRangeChunk {
+			return written, err
+		}
+	}
+}
+
+// reflink 通过FICLONE让目标文件与源文件共享数据块，btrfs、xfs等写时复制文件系统支持
+func reflink(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ioctlFiclone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package fanpath
+
+import "os"
+
+// copyContents 拷贝文件内容，非linux平台使用缓冲拷贝
+func copyContents(dst, src *os.File, _ int64, onWrite func(n int64)) (int64, error) {
+	return copyBuffered(dst, src, onWrite)
+}
@@ -0,0 +1,376 @@
+package fanpath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestFile creates a file with the given content under root.
+func writeTestFile(t *testing.T, root, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func readTestFile(t *testing.T, root, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestCopyDirKeepsStructure(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "out")
+
+	writeTestFile(t, src, "a.txt", "root")
+	writeTestFile(t, src, "sub/a.txt", "nested")
+	writeTestFile(t, src, "sub/deeper/b.txt", "deep")
+	if err := os.MkdirAll(filepath.Join(src, "empty"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exe := writeTestFile(t, src, "bin/tool", "#!/bin/sh")
+	if err := os.Chmod(exe, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(exe, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatalf("CopyDir failed: %v", err)
+	}
+
+	// Files with the same base name in different directories must not clash.
+	for rel, want := range map[string]string{"a.txt": "root", "sub/a.txt": "nested", "sub/deeper/b.txt": "deep"} {
+		if got := readTestFile(t, dst, rel); got != want {
+			t.Errorf("%s = %q; want %q", rel, got, want)
+		}
+	}
+	if !ExistDir(filepath.Join(dst, "empty")) {
+		t.Error("empty directory not copied")
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "bin", "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v; want %v", info.ModTime(), mtime)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v; want 0755", info.Mode().Perm())
+	}
+}
+
+func TestCopyDirIntoOwnSubdirectory(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src, "a.txt", "a")
+	writeTestFile(t, src, "sub/b.txt", "b")
+
+	dst := filepath.Join(src, "sub", "backup")
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatalf("CopyDir failed: %v", err)
+	}
+	if got := readTestFile(t, dst, "sub/b.txt"); got != "b" {
+		t.Errorf("sub/b.txt = %q; want \"b\"", got)
+	}
+	if ExistPath(filepath.Join(dst, "sub", "backup")) {
+		t.Error("destination was copied into itself")
+	}
+
+	if err := CopyDir(src, src); err == nil {
+		t.Error("CopyDir onto itself succeeded")
+	}
+}
+
+func TestCopyDirSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+
+	src := t.TempDir()
+	writeTestFile(t, src, "data/file.txt", "content")
+	if err := os.Symlink("data/file.txt", filepath.Join(src, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("data", filepath.Join(src, "dir-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Follow: links become real copies.
+	dst := t.TempDir()
+	if _, err := CopyDirWithOptions(src, dst, CopyOptions{Symlinks: SymlinkFollow}); err != nil {
+		t.Fatalf("CopyDir follow failed: %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "dir-link")); err != nil || !info.IsDir() {
+		t.Errorf("followed dir-link is not a directory: %v %v", info, err)
+	}
+	if got := readTestFile(t, dst, "dir-link/file.txt"); got != "content" {
+		t.Errorf("dir-link/file.txt = %q", got)
+	}
+
+	// Copy: links are recreated verbatim.
+	dst = t.TempDir()
+	if _, err := CopyDirWithOptions(src, dst, CopyOptions{Symlinks: SymlinkCopy}); err != nil {
+		t.Fatalf("CopyDir copy-link failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "file-link")); err != nil || target != "data/file.txt" {
+		t.Errorf("file-link target = %q, %v", target, err)
+	}
+
+	// Skip: links are left out.
+	dst = t.TempDir()
+	if _, err := CopyDirWithOptions(src, dst, CopyOptions{Symlinks: SymlinkSkip}); err != nil {
+		t.Fatalf("CopyDir skip failed: %v", err)
+	}
+	if ExistPath(filepath.Join(dst, "file-link")) || ExistPath(filepath.Join(dst, "dir-link")) {
+		t.Error("symlinks copied in skip mode")
+	}
+
+	// A loop is reported instead of recursing forever.
+	if err := os.Symlink("..", filepath.Join(src, "data", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyDirWithOptions(src, t.TempDir(), CopyOptions{Symlinks: SymlinkFollow}); err == nil {
+		t.Error("CopyDir followed a symlink loop without error")
+	}
+}
+
+func TestCopyDirFilters(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src, "main.go", "package main")
+	writeTestFile(t, src, "main_test.go", "package main")
+	writeTestFile(t, src, "README.md", "readme")
+	writeTestFile(t, src, "assets/logo.png", "png")
+	writeTestFile(t, src, "assets/raw/logo.psd", "psd")
+	writeTestFile(t, src, "vendor/lib/lib.go", "package lib")
+
+	dst := t.TempDir()
+	summary, err := CopyDirWithOptions(src, dst, CopyOptions{
+		Include:       []string{"*.go", "assets/*"},
+		Exclude:       []string{"vendor"},
+		ExcludeRegexp: []*regexp.Regexp{regexp.MustCompile(`_test\.go$`)},
+	})
+	if err != nil {
+		t.Fatalf("CopyDirWithOptions failed: %v", err)
+	}
+
+	sort.Strings(summary.Copied)
+	if want := []string{"assets/logo.png", "main.go"}; !reflect.DeepEqual(summary.Copied, want) {
+		t.Errorf("Copied = %v; want %v", summary.Copied, want)
+	}
+	sort.Strings(summary.Skipped)
+	if want := []string{"README.md", "assets/raw/logo.psd", "main_test.go"}; !reflect.DeepEqual(summary.Skipped, want) {
+		t.Errorf("Skipped = %v; want %v", summary.Skipped, want)
+	}
+	if ExistPath(filepath.Join(dst, "vendor")) {
+		t.Error("excluded directory was created")
+	}
+
+	if _, err := CopyDirWithOptions(src, dst, CopyOptions{Include: []string{"[a-"}}); err == nil {
+		t.Error("invalid glob accepted")
+	}
+}
+
+func TestCopyDirOverwritePolicy(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	setup := func(t *testing.T) (string, string) {
+		src, dst := t.TempDir(), t.TempDir()
+		writeTestFile(t, src, "same.txt", "same")
+		writeTestFile(t, dst, "same.txt", "same")
+		writeTestFile(t, src, "changed.txt", "new")
+		writeTestFile(t, dst, "changed.txt", "old")
+		writeTestFile(t, src, "stale.txt", "src")
+		writeTestFile(t, dst, "stale.txt", "dst is newer")
+		for _, path := range []string{filepath.Join(dst, "same.txt"), filepath.Join(dst, "changed.txt"), filepath.Join(src, "stale.txt")} {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeTestFile(t, src, "fresh.txt", "fresh")
+		return src, dst
+	}
+
+	tests := []struct {
+		policy OverwritePolicy
+		copied []string
+	}{
+		{OverwriteAlways, []string{"changed.txt", "fresh.txt", "same.txt", "stale.txt"}},
+		{OverwriteNever, []string{"fresh.txt"}},
+		{OverwriteIfNewer, []string{"changed.txt", "fresh.txt", "same.txt"}},
+		{OverwriteIfDifferent, []string{"changed.txt", "fresh.txt", "stale.txt"}},
+	}
+	for _, tt := range tests {
+		src, dst := setup(t)
+		summary, err := CopyDirWithOptions(src, dst, CopyOptions{Overwrite: tt.policy})
+		if err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+		sort.Strings(summary.Copied)
+		if !reflect.DeepEqual(summary.Copied, tt.copied) {
+			t.Errorf("policy %d: Copied = %v; want %v", tt.policy, summary.Copied, tt.copied)
+		}
+	}
+}
+
+func TestCopyDirDryRunAndProgress(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src, "a.txt", "12345")
+	writeTestFile(t, src, "sub/b.txt", "678")
+
+	dst := filepath.Join(t.TempDir(), "out")
+	summary, err := CopyDirWithOptions(src, dst, CopyOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(summary.Copied) != 2 || summary.Bytes != 8 {
+		t.Errorf("dry run summary = %+v; want 2 files, 8 bytes", summary)
+	}
+	if ExistPath(dst) {
+		t.Error("dry run created the destination")
+	}
+
+	var last CopyProgress
+	calls := 0
+	summary, err = CopyDirWithOptions(src, dst, CopyOptions{Progress: func(p CopyProgress) {
+		if p.Bytes < last.Bytes || p.Files < last.Files {
+			t.Errorf("progress went backwards: %+v after %+v", p, last)
+		}
+		last = p
+		calls++
+	}})
+	if err != nil {
+		t.Fatalf("CopyDirWithOptions failed: %v", err)
+	}
+	if last.Files != 2 || last.Bytes != 8 || summary.Bytes != 8 || calls < 2 {
+		t.Errorf("last progress = %+v after %d calls, summary bytes %d", last, calls, summary.Bytes)
+	}
+}
+
+func TestCopyBytesExcludeFailedFiles(t *testing.T) {
+	var last CopyProgress
+	c := &treeCopier{summary: &CopySummary{}, opts: CopyOptions{Progress: func(p CopyProgress) { last = p }}}
+
+	// A file that fails halfway streams progress but is not counted.
+	c.reportBytes("broken.bin", 100)
+	if last.FileBytes != 100 || last.Bytes != 0 {
+		t.Errorf("streaming progress = %+v; want FileBytes 100, Bytes 0", last)
+	}
+	c.finishFile("broken.bin", 100, errors.New("read failed"))
+	c.reportBytes("ok.bin", 40)
+	c.finishFile("ok.bin", 40, nil)
+
+	if c.summary.Bytes != 40 || len(c.summary.Failed) != 1 || len(c.summary.Copied) != 1 {
+		t.Errorf("summary = %+v; want 40 bytes from the copied file only", c.summary)
+	}
+	if last.Bytes != 40 || last.Files != 2 {
+		t.Errorf("last progress = %+v; want Bytes 40 after 2 files", last)
+	}
+}
+
+func TestCopyDirParallel(t *testing.T) {
+	src := t.TempDir()
+	want := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		rel := fmt.Sprintf("d%d/f%d.bin", i%7, i)
+		content := strings.Repeat(fmt.Sprint(i), i*100)
+		writeTestFile(t, src, rel, content)
+		want[rel] = content
+	}
+
+	dst := t.TempDir()
+	var files int
+	summary, err := CopyDirWithOptions(src, dst, CopyOptions{
+		Workers:  4,
+		Progress: func(p CopyProgress) { files = p.Files },
+	})
+	if err != nil {
+		t.Fatalf("CopyDirWithOptions failed: %v", err)
+	}
+	if len(summary.Copied) != len(want) || files != len(want) {
+		t.Errorf("copied %d files, progress reported %d; want %d", len(summary.Copied), files, len(want))
+	}
+	for rel, content := range want {
+		if got := readTestFile(t, dst, rel); got != content {
+			t.Errorf("%s has %d bytes; want %d", rel, len(got), len(content))
+		}
+	}
+}
+
+func TestCopyContents(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3<<16) // larger than one buffer
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	copiers := map[string]func(dst, src *os.File, onWrite func(n int64)) (int64, error){
+		"native": func(dst, src *os.File, onWrite func(n int64)) (int64, error) {
+			return copyContents(dst, src, int64(len(content)), onWrite)
+		},
+		"buffered": copyBuffered,
+	}
+	for name, copier := range copiers {
+		t.Run(name, func(t *testing.T) {
+			in, err := os.Open(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+			out, err := os.Create(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+
+			var reported int64
+			n, err := copier(out, in, func(n int64) { reported += n })
+			if err != nil || n != int64(len(content)) || reported != n {
+				t.Fatalf("copied %d bytes (reported %d), err %v; want %d", n, reported, err, len(content))
+			}
+			got, err := os.ReadFile(out.Name())
+			if err != nil || !bytes.Equal(got, content) {
+				t.Fatalf("content mismatch: %d bytes, err %v", len(got), err)
+			}
+		})
+	}
+}
+
+func TestCopyFileFromProcfs(t *testing.T) {
+	// procfs reports size 0 and older kernels return 0 from copy_file_range for
+	// it; the copy must still produce the real content.
+	const src = "/proc/self/status"
+	if _, err := os.Stat(src); err != nil {
+		t.Skip("procfs not available")
+	}
+	dst := filepath.Join(t.TempDir(), "status")
+	if err := CopyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil || !bytes.HasPrefix(got, []byte("Name:")) {
+		t.Errorf("copied procfs file = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,141 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// execPath 可执行文件路径，首次使用时解析
+var execPath struct {
+	once sync.Once
+	mu   sync.RWMutex
+	file string // 解析符号链接后的可执行文件路径
+	dir  string // 可执行文件所在目录，go run/go test时为模块根目录
+	err  error
+}
+
+// loadExecutePath 返回可执行文件路径和所在目录，第一次调用时解析
+func loadExecutePath() (file, dir string, err error) {
+	execPath.once.Do(func() {
+		file, dir, err := resolveExecutePath()
+		execPath.mu.Lock()
+		execPath.file, execPath.dir, execPath.err = file, dir, err
+		execPath.mu.Unlock()
+	})
+	execPath.mu.RLock()
+	defer execPath.mu.RUnlock()
+	return execPath.file, execPath.dir, execPath.err
+}
+
+// resolveExecutePath 解析可执行文件的真实路径，go run/go test生成的临时文件以模块根目录作为所在目录
+func resolveExecutePath() (file, dir string, err error) {
+	file, err = os.Executable()
+	if err != nil {
+		return "", "", err
+	}
+	if real, err := filepath.EvalSymlinks(file); err == nil {
+		file = real
+	}
+	if file, err = filepath.Abs(file); err != nil {
+		return "", "", err
+	}
+
+	if !isGoBuildTemp(file) {
+		return file, filepath.Dir(file), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	return file, findModuleRoot(wd), nil
+}
+
+// isGoBuildTemp 是否是go run/go test在go-build临时目录中生成的可执行文件
+func isGoBuildTemp(file string) bool {
+	for dir := filepath.Dir(file); filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		// 临时目录形如go-build123456，后面全是数字
+		name := filepath.Base(dir)
+		if suffix := strings.TrimPrefix(name, "go-build"); suffix != name && suffix != "" && strings.Trim(suffix, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findModuleRoot 从dir向上查找包含go.mod的目录，找不到时返回dir
+func findModuleRoot(dir string) string {
+	for cur := dir; ; cur = filepath.Dir(cur) {
+		if ExistFile(filepath.Join(cur, "go.mod")) {
+			return cur
+		}
+		if filepath.Dir(cur) == cur {
+			return dir
+		}
+	}
+}
+
+// InitExecutePath 解析可执行文件路径，其他函数在首次使用时会自动解析，调用它只是为了尽早得到错误
+func InitExecutePath() error {
+	_, _, err := loadExecutePath()
+	return err
+}
+
+// SetExecutePath 把可执行文件路径替换为file，所在目录为dir，返回恢复原值的函数，用于测试
+func SetExecutePath(file, dir string) (restore func()) {
+	loadExecutePath()
+	execPath.mu.Lock()
+	defer execPath.mu.Unlock()
+	oldFile, oldDir, oldErr := execPath.file, execPath.dir, execPath.err
+	execPath.file, execPath.dir, execPath.err = file, dir, nil
+	return func() {
+		execPath.mu.Lock()
+		defer execPath.mu.Unlock()
+		execPath.file, execPath.dir, execPath.err = oldFile, oldDir, oldErr
+	}
+}
+
+// ExecuteFilePath 可执行文件的路径，已解析符号链接
+func ExecuteFilePath() string {
+	file, _, _ := loadExecutePath()
+	return file
+}
+
+// ExecuteParentPath 可执行文件所在目录，go run/go test时为模块根目录
+func ExecuteParentPath() string {
+	_, dir, _ := loadExecutePath()
+	return dir
+}
+
+// RelExecuteDir 获取相对可执行文件所在目录
+func RelExecuteDir(paths ...string) string {
+	paths = append([]string{ExecuteParentPath()}, paths...)
+	return filepath.Join(paths...)
+}
+
+// AbsOrRelExecutePath 获取绝对路径或者相对可执行文件所在目录的路径
+func AbsOrRelExecutePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return RelExecuteDir(path)
+}
+
+// SecureRelExecuteDir 把不可信的相对路径拼接到可执行文件所在目录下，结果不会超出该目录，规则同SecureJoin
+func SecureRelExecuteDir(untrusted string) (string, error) {
+	_, dir, err := loadExecutePath()
+	if err != nil {
+		return "", err
+	}
+	return SecureJoin(dir, untrusted)
+}
+
+// SetWorkDirToExecuteDir 把工作目录设置为exe的目录
+func SetWorkDirToExecuteDir() error {
+	_, dir, err := loadExecutePath()
+	if err != nil {
+		return err
+	}
+	return os.Chdir(dir)
+}
@@ -0,0 +1,61 @@
+package fanpath
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExecutePathUnderGoTest(t *testing.T) {
+	// go test runs a temporary binary, so the directory falls back to the module root.
+	if !isGoBuildTemp(ExecuteFilePath()) {
+		t.Skipf("%s is not a go-build temporary binary", ExecuteFilePath())
+	}
+	if !ExistFile(filepath.Join(ExecuteParentPath(), "go.mod")) {
+		t.Errorf("ExecuteParentPath() = %s; want the module root", ExecuteParentPath())
+	}
+	if err := InitExecutePath(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsGoBuildTemp(t *testing.T) {
+	cases := map[string]bool{
+		filepath.Join("/tmp", "go-build123", "b001", "exe", "main"):     true,
+		filepath.Join("/tmp", "go-build456", "b001", "fanpath.test"):    true,
+		filepath.Join("/usr", "local", "bin", "tool"):                   false,
+		filepath.Join("/home", "u", "go-builder", "bin", "tool"):        false,
+		filepath.Join("/home", "u", "projects", "go-tools", "bin", "x"): false,
+	}
+	for file, want := range cases {
+		if got := isGoBuildTemp(file); got != want {
+			t.Errorf("isGoBuildTemp(%s) = %v; want %v", file, got, want)
+		}
+	}
+}
+
+func TestSetExecutePath(t *testing.T) {
+	dir := t.TempDir()
+	before := ExecuteParentPath()
+
+	restore := SetExecutePath(filepath.Join(dir, "app"), dir)
+	if got := RelExecuteDir("conf", "a.ini"); got != filepath.Join(dir, "conf", "a.ini") {
+		t.Errorf("RelExecuteDir = %s", got)
+	}
+	if got := AbsOrRelExecutePath("data"); got != filepath.Join(dir, "data") {
+		t.Errorf("AbsOrRelExecutePath = %s", got)
+	}
+	restore()
+
+	if got := ExecuteParentPath(); got != before {
+		t.Errorf("after restore ExecuteParentPath() = %s; want %s", got, before)
+	}
+}
+
+func TestFindModuleRoot(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "go.mod", "module example")
+	writeTestFile(t, root, "a/b/c.go", "package b")
+	if got := findModuleRoot(filepath.Join(root, "a", "b")); got != root {
+		t.Errorf("findModuleRoot = %s; want %s", got, root)
+	}
+}
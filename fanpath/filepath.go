@@ -1,58 +1,13 @@
 package fanpath
 
 import (
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
 )
 
-var (
-	executeDir  string
-	exeFilePath string
-)
-
-func InitExecutePath() error {
-	p, err := os.Executable()
-	if err != nil {
-		return err
-	}
-	exeFilePath = p
-	executeDir = filepath.Dir(exeFilePath)
-	return nil
-}
-
-func ExecuteFilePath() string {
-	return exeFilePath
-}
-
-func ExecuteParentPath() string {
-	return executeDir
-}
-
-// RelExecuteDir 获取相对可执行文件所在目录
-func RelExecuteDir(paths ...string) string {
-	paths = append([]string{executeDir}, paths...)
-	return filepath.Join(paths...)
-}
-
-// AbsOrRelExecutePath 获取绝对路径或者相对可执行文件所在目录的路径
-func AbsOrRelExecutePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
-	}
-	return RelExecuteDir(path)
-}
-
-// SetWorkDirToExecuteDir 把工作目录设置为exe的目录
-func SetWorkDirToExecuteDir() error {
-	err := os.Chdir(executeDir)
-	return err
-}
-
 // ExistPath 路径是否存在
 func ExistPath(path string) bool {
 	_, err := os.Stat(path)
@@ -87,114 +42,77 @@ func CreateDirIfNoExist(path string) error {
 	return os.MkdirAll(path, os.ModePerm)
 }
 
-// GetFileListByExt 获取某个目录下ext扩展名的所有文件
+// GetFileListByExt 获取某个目录下ext扩展名的所有文件，ext以"."开头，可以是多级扩展名，如".tar.gz"；ext为空时获取没有扩展名的文件
+// 无法读取的子目录被跳过，dir本身是文件时按该文件判断
 func GetFileListByExt(dir string, ext string) ([]string, error) {
-	var fileLists []string
-	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
-		if f == nil {
-			return err
-		}
-		if f.IsDir() {
-			return nil
-		}
-		if filepath.Ext(path) == ext {
-			fileLists = append(fileLists, path)
-		}
-		return nil
-	})
-	return fileLists, err
+	return GlobWithOptions(dir, extGlobOptions(ext), extPattern(ext))
 }
 
-// ClearDirAndCreateNew 清空目录并重新创建
-func ClearDirAndCreateNew(path string) error {
-	if ExistPath(path) {
-		err := os.RemoveAll(path)
-		if err != nil {
-			return err
+// extPattern 匹配任意层级ext扩展名文件的glob规则，ext为空时匹配所有文件，由extGlobOptions过滤
+func extPattern(ext string) string {
+	return "**/*" + escapeGlob(ext)
+}
+
+// extGlobOptions 按扩展名查找文件时的Glob选项
+// 规则无法表达"没有扩展名"和"不以.开头的ext不匹配任何文件"，这两种情况按filepath.Ext过滤文件名
+func extGlobOptions(ext string) GlobOptions {
+	opts := GlobOptions{SkipUnreadable: true}
+	if !strings.HasPrefix(ext, ".") {
+		opts.FileFilter = func(name string) bool {
+			return ext == "" && filepath.Ext(name) == ""
 		}
 	}
-	err := os.MkdirAll(path, os.ModePerm)
+	return opts
+}
+
+// ClearDirAndCreateNew 清空目录并重新创建，拒绝删除根目录和用户主目录，见CheckRemovable
+func ClearDirAndCreateNew(path string) error {
+	_, err := ClearDirAndCreateNewWithOptions(path, RemoveOptions{})
 	return err
 }
 
-// InitDirAndClearFile 初始化目录并清空目录下指定文件
+// InitDirAndClearFile 初始化目录并清空目录下指定文件，removePattern为匹配完整路径的正则表达式
+// 与ClearDirAndCreateNew相同，path是根目录、用户主目录或主目录的上级目录时返回ErrUnsafePath，不做任何修改
+// 匹配相对路径的版本见InitDirAndClearFileWithOptions
 func InitDirAndClearFile(path string, removePattern string) error {
-	if !ExistPath(path) {
-		err := os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			return err
-		}
-	}
 	reg, err := regexp.Compile(removePattern)
 	if err != nil {
 		return err
 	}
-	err = filepath.Walk(path, func(fileName string, f os.FileInfo, err error) error {
-		if ok := reg.MatchString(fileName); !ok {
-			return nil
-		}
-		err = os.Remove(fileName)
-		return err
-	})
-	return err
+	return initDirAndClear(path, GlobOptions{IncludeDirs: true}, func(fileName string) bool {
+		return reg.MatchString(fileName)
+	}, "**")
 }
 
-// CopyFile 拷贝文件
-func CopyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
-	err = CreateDirIfNoExist(filepath.Dir(dst))
-	if err != nil {
-		return err
-	}
-
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
+// InitDirAndClearGlob 初始化目录并删除目录下匹配glob规则的文件，规则同Glob，拒绝的路径同InitDirAndClearFile
+func InitDirAndClearGlob(path string, patterns ...string) error {
+	return initDirAndClear(path, GlobOptions{}, nil, patterns...)
+}
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+// initDirAndClear 创建目录，删除Glob找到且满足filter的路径，filter为nil时删除全部
+func initDirAndClear(path string, opts GlobOptions, filter func(fileName string) bool, patterns ...string) error {
+	if err := CheckRemovable(path, RemoveOptions{}); err != nil {
 		return err
 	}
-
-	return dstFile.Sync()
-}
-
-// CopyDir 拷贝目录
-func CopyDir(srcDir, dstDir string) error {
-	if !ExistPath(dstDir) {
-		err := os.MkdirAll(dstDir, os.ModePerm)
+	if !ExistPath(path) {
+		err := os.MkdirAll(path, os.ModePerm)
 		if err != nil {
 			return err
 		}
 	}
-	return filepath.Walk(srcDir, func(srcPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Ignore the source directory.
-		if srcPath == srcDir {
-			return nil
+	fileNames, err := GlobWithOptions(path, opts, patterns...)
+	if err != nil {
+		return err
+	}
+	for _, fileName := range fileNames {
+		if filter != nil && !filter(fileName) {
+			continue
 		}
-
-		baseFileName := filepath.Base(srcPath)
-		// Calculate the destination path.
-		dstPath := filepath.Join(dstDir, baseFileName)
-
-		// Check if it's a directory, create it if so.
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
+		if err := os.Remove(fileName); err != nil {
+			return err
 		}
-
-		// It's a file, so copy it.
-		return CopyFile(srcPath, dstPath)
-	})
+	}
+	return nil
 }
 
 // GetFileNameWithoutExt 获取文件名，不带后缀
@@ -219,29 +137,15 @@ func ReadDir(dirName string) ([]os.FileInfo, error) {
 	return infoList, nil
 }
 
-// ByModTime 实现了sort.Interface，用于按修改时间排序文件。
-type byModTime []os.FileInfo
-
-func (a byModTime) Len() int           { return len(a) }
-func (a byModTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byModTime) Less(i, j int) bool { return a[i].ModTime().Before(a[j].ModTime()) }
-
-// GetFileListByModTime 获取一个目录下的所有文件，并且按修改时间排序
+// GetFileListByModTime 获取一个目录下的所有文件（不包括目录，不递归），并且按修改时间排序
 func GetFileListByModTime(dir string) ([]string, error) {
-	var fileLists []string
-	fileInfoList, err := ReadDir(dir)
+	entries, err := List(dir, ListOptions{Sort: SortByModTime, MaxDepth: 1, Types: ListFiles | ListSymlinks})
 	if err != nil {
-		return fileLists, err
+		return nil, err
 	}
-
-	sortFileInfo := byModTime(fileInfoList)
-	sort.Sort(sortFileInfo)
-
-	for _, fileInfo := range fileInfoList {
-		if fileInfo.IsDir() {
-			continue
-		}
-		fileLists = append(fileLists, filepath.Join(dir, fileInfo.Name()))
+	fileLists := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fileLists = append(fileLists, entry.Path)
 	}
 	return fileLists, nil
 }
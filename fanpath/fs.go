@@ -0,0 +1,109 @@
+package fanpath
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExistPathFS fsys中路径是否存在
+func ExistPathFS(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+// ExistFileFS fsys中文件是否存在
+func ExistFileFS(fsys fs.FS, name string) bool {
+	f, err := fs.Stat(fsys, name)
+	if err != nil {
+		return false
+	}
+
+	return !f.IsDir()
+}
+
+// ExistDirFS fsys中文件夹是否存在
+func ExistDirFS(fsys fs.FS, name string) bool {
+	f, err := fs.Stat(fsys, name)
+	if err != nil {
+		return false
+	}
+
+	return f.IsDir()
+}
+
+// GetFileListByExtFS 获取fsys中某个目录下ext扩展名的所有文件，返回以/分隔的fsys路径
+// 扩展名规则同GetFileListByExt，无法读取的子目录被跳过
+func GetFileListByExtFS(fsys fs.FS, dir string, ext string) ([]string, error) {
+	return GlobFSWithOptions(fsys, dir, extGlobOptions(ext), extPattern(ext))
+}
+
+// CopyFileFromFS 把fsys中的文件拷贝到磁盘，通过AtomicWriter写入
+// 文件权限取自fsys并保证所有者可写（embed.FS中的文件都是只读的），修改时间非零时保留
+func CopyFileFromFS(fsys fs.FS, name, dst string) error {
+	srcFile, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	w, err := NewAtomicWriter(dst, info.Mode().Perm()|0200)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, srcFile); err != nil {
+		return err
+	}
+	if err := w.Commit(); err != nil {
+		return err
+	}
+	if !info.ModTime().IsZero() {
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// CopyDirFromFS 把fsys中的srcDir目录树拷贝到磁盘的dstDir，常用于释放embed.FS中的资源
+// 目录权限同样保证所有者可写，fs.FS中的符号链接和特殊文件会被跳过
+func CopyDirFromFS(fsys fs.FS, srcDir, dstDir string) error {
+	return fs.WalkDir(fsys, srcDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, filepath.FromSlash(relFS(srcDir, name)))
+
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(dst, info.Mode().Perm()|0700)
+		case d.Type().IsRegular():
+			return CopyFileFromFS(fsys, name, dst)
+		default:
+			return nil
+		}
+	})
+}
+
+// relFS fs.WalkDir得到的name相对root的路径
+func relFS(root, name string) string {
+	switch {
+	case name == root:
+		return "."
+	case root == ".":
+		return name
+	default:
+		return strings.TrimPrefix(name, root+"/")
+	}
+}
@@ -0,0 +1,86 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testFS() fstest.MapFS {
+	mtime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"assets/app.css":       {Data: []byte("body{}"), Mode: 0444, ModTime: mtime},
+		"assets/img/logo.png":  {Data: []byte("png"), Mode: 0444},
+		"assets/img/raw.psd":   {Data: []byte("psd"), Mode: 0444},
+		"assets/scripts/a.js":  {Data: []byte("a"), Mode: 0755},
+		"templates/index.html": {Data: []byte("<html>")},
+	}
+}
+
+func TestExistFS(t *testing.T) {
+	fsys := testFS()
+	if !ExistPathFS(fsys, "assets") || !ExistDirFS(fsys, "assets/img") || ExistFileFS(fsys, "assets") {
+		t.Error("directory checks failed")
+	}
+	if !ExistFileFS(fsys, "assets/app.css") || ExistDirFS(fsys, "assets/app.css") {
+		t.Error("file checks failed")
+	}
+	if ExistPathFS(fsys, "missing") {
+		t.Error("missing path reported as existing")
+	}
+}
+
+func TestGetFileListByExtFS(t *testing.T) {
+	got, err := GetFileListByExtFS(testFS(), "assets", ".png")
+	if err != nil {
+		t.Fatalf("GetFileListByExtFS failed: %v", err)
+	}
+	if want := []string{"assets/img/logo.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	got, err = GlobFS(testFS(), ".", "**/*", "!**/*.psd", "!templates")
+	if err != nil {
+		t.Fatalf("GlobFS failed: %v", err)
+	}
+	if want := []string{"assets/app.css", "assets/img/logo.png", "assets/scripts/a.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GlobFS = %v; want %v", got, want)
+	}
+}
+
+func TestCopyDirFromFS(t *testing.T) {
+	dst := t.TempDir()
+	if err := CopyDirFromFS(testFS(), "assets", dst); err != nil {
+		t.Fatalf("CopyDirFromFS failed: %v", err)
+	}
+
+	if got := readTestFile(t, dst, "img/logo.png"); got != "png" {
+		t.Errorf("img/logo.png = %q", got)
+	}
+	if ExistPath(filepath.Join(dst, "templates")) || ExistPath(filepath.Join(dst, "assets")) {
+		t.Error("copied outside the requested subtree")
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "app.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("mtime = %v", info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v; want read-only source made owner-writable (0644)", info.Mode().Perm())
+	}
+
+	// Extracting again over the existing read-only-sourced files works.
+	if err := CopyDirFromFS(testFS(), ".", dst); err != nil {
+		t.Fatalf("second CopyDirFromFS failed: %v", err)
+	}
+	if got := readTestFile(t, dst, "templates/index.html"); got != "<html>" {
+		t.Errorf("templates/index.html = %q", got)
+	}
+}
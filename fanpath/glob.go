@@ -0,0 +1,334 @@
+package fanpath
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GlobOptions Glob的选项
+type GlobOptions struct {
+	CaseInsensitive bool                   // 匹配时忽略大小写
+	IncludeDirs     bool                   // 结果中包含匹配的目录，默认只返回文件
+	IgnoreFiles     []string               // 忽略规则文件名，如".gitignore"，每个目录下的同名文件按gitignore语法生效，被忽略的目录不再遍历
+	SkipUnreadable  bool                   // 跳过无法读取的子目录，默认返回错误；root自身无法读取时总是返回错误
+	FileFilter      func(name string) bool // 非nil时文件名还需要满足它才会返回，不影响目录的遍历和匹配，如按规则无法表达的"没有扩展名"
+}
+
+// Glob 在root下查找匹配任意一条规则的文件，返回包含root前缀的路径，按目录遍历顺序排列
+// 规则使用/分隔、相对于root，支持path.Match的语法，另外"**"可以匹配任意层级（包括零层）的目录；
+// 以"!"开头的规则为排除规则，被排除的目录不再遍历；只有排除规则时匹配所有文件
+// root是文件时按文件名匹配规则，匹配时返回root自身
+func Glob(root string, patterns ...string) ([]string, error) {
+	return GlobWithOptions(root, GlobOptions{}, patterns...)
+}
+
+// GlobWithOptions 按选项查找文件，规则同Glob
+func GlobWithOptions(root string, opts GlobOptions, patterns ...string) ([]string, error) {
+	g, err := newGlobber(opts, patterns)
+	if err != nil {
+		return nil, err
+	}
+	g.readDir = func(rel string) ([]fs.DirEntry, error) {
+		return os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	}
+	g.open = func(rel string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	}
+	g.join = func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		g.matchRoot(info.Name(), root)
+		return g.matches, nil
+	}
+	if err := g.walk("", nil); err != nil {
+		return nil, err
+	}
+	return g.matches, nil
+}
+
+// GlobFS 在fsys的root目录下查找匹配的文件，返回fsys中以/分隔的路径，规则同Glob
+func GlobFS(fsys fs.FS, root string, patterns ...string) ([]string, error) {
+	return GlobFSWithOptions(fsys, root, GlobOptions{}, patterns...)
+}
+
+// GlobFSWithOptions 按选项在fsys中查找文件，规则同Glob
+func GlobFSWithOptions(fsys fs.FS, root string, opts GlobOptions, patterns ...string) ([]string, error) {
+	g, err := newGlobber(opts, patterns)
+	if err != nil {
+		return nil, err
+	}
+	g.readDir = func(rel string) ([]fs.DirEntry, error) {
+		return fs.ReadDir(fsys, path.Join(root, rel))
+	}
+	g.open = func(rel string) (io.ReadCloser, error) {
+		return fsys.Open(path.Join(root, rel))
+	}
+	g.join = func(rel string) string {
+		return path.Join(root, rel)
+	}
+	if info, err := fs.Stat(fsys, root); err == nil && !info.IsDir() {
+		g.matchRoot(info.Name(), root)
+		return g.matches, nil
+	}
+	if err := g.walk("", nil); err != nil {
+		return nil, err
+	}
+	return g.matches, nil
+}
+
+// newGlobber 编译规则，返回的globber还需要设置访问文件系统的函数
+func newGlobber(opts GlobOptions, patterns []string) (*globber, error) {
+	var includes, excludes []globPattern
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		p, err := compileGlob(strings.TrimPrefix(pattern, "!"), opts.CaseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			excludes = append(excludes, p)
+		} else {
+			includes = append(includes, p)
+		}
+	}
+	if len(includes) == 0 {
+		includes = append(includes, globPattern{segments: []string{"**"}})
+	}
+
+	return &globber{opts: opts, includes: includes, excludes: excludes}, nil
+}
+
+// globber 遍历目录并收集匹配的路径，通过readDir、open、join访问操作系统或fs.FS
+type globber struct {
+	opts     GlobOptions
+	includes []globPattern
+	excludes []globPattern
+	matches  []string
+
+	readDir func(rel string) ([]fs.DirEntry, error)
+	open    func(rel string) (io.ReadCloser, error)
+	join    func(rel string) string // 把相对路径转换为结果中的路径
+}
+
+// walk 遍历rel目录，rules为从root到rel所有忽略文件中的规则
+func (g *globber) walk(rel string, rules []ignoreRule) error {
+	entries, err := g.readDir(rel)
+	if err != nil {
+		if rel != "" && g.opts.SkipUnreadable {
+			return nil
+		}
+		return err
+	}
+
+	for _, name := range g.opts.IgnoreFiles {
+		fileRules, err := g.readIgnoreFile(path.Join(rel, name), rel)
+		if err != nil {
+			return err
+		}
+		// 复制一份，避免兄弟目录之间共享底层数组
+		rules = append(rules[:len(rules):len(rules)], fileRules...)
+	}
+
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		isDir := entry.IsDir()
+		if ignored(rules, entryRel, isDir) || matchAny(g.excludes, entryRel, isDir) {
+			continue
+		}
+
+		if g.accept(entryRel, entry.Name(), isDir) {
+			g.matches = append(g.matches, g.join(entryRel))
+		}
+		if isDir {
+			if err := g.walk(entryRel, rules); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// accept 没有被排除的路径是否应当出现在结果中
+func (g *globber) accept(rel, name string, isDir bool) bool {
+	if isDir && !g.opts.IncludeDirs {
+		return false
+	}
+	if !isDir && g.opts.FileFilter != nil && !g.opts.FileFilter(name) {
+		return false
+	}
+	return matchAny(g.includes, rel, isDir)
+}
+
+// matchRoot root是文件时，按文件名name匹配规则，匹配时结果为root自身
+func (g *globber) matchRoot(name, root string) {
+	if !matchAny(g.excludes, name, false) && g.accept(name, name, false) {
+		g.matches = append(g.matches, root)
+	}
+}
+
+// globPattern 编译后的glob规则
+type globPattern struct {
+	segments []string // 按/分隔的各段，"**"匹配任意层级
+	dirOnly  bool     // 只匹配目录（gitignore中以/结尾的规则）
+	fold     bool     // 忽略大小写，segments已转为小写
+}
+
+// compileGlob 编译glob规则，检查每一段的语法
+func compileGlob(pattern string, fold bool) (globPattern, error) {
+	if fold {
+		pattern = strings.ToLower(pattern)
+	}
+	p := globPattern{fold: fold}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return p, errors.New("invalid pattern: empty")
+	}
+
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "**" {
+			// 连续的**等价于一个
+			if n := len(p.segments); n > 0 && p.segments[n-1] == "**" {
+				continue
+			}
+		} else if _, err := path.Match(segment, ""); err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		p.segments = append(p.segments, segment)
+	}
+	return p, nil
+}
+
+// match 以/分隔的相对路径是否匹配
+func (p globPattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.fold {
+		rel = strings.ToLower(rel)
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments 逐段匹配，"**"可以匹配零个或多个段
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAny 是否匹配任意一条规则
+func matchAny(patterns []globPattern, rel string, isDir bool) bool {
+	for _, p := range patterns {
+		if p.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule 忽略文件中的一条规则
+type ignoreRule struct {
+	pattern globPattern
+	base    string // 忽略文件所在目录相对root的路径
+	negate  bool   // 以!开头，重新包含之前被忽略的路径
+}
+
+// ignored 按gitignore的语义判断路径是否被忽略，后面的规则优先
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if rule.pattern.match(sub, isDir) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// readIgnoreFile 读取gitignore格式的忽略文件，文件不存在时返回空
+// 不含/的规则匹配任意层级的文件名，含/的规则相对忽略文件所在目录
+func (g *globber) readIgnoreFile(filePath, base string) ([]ignoreRule, error) {
+	file, err := g.open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // \#和\!转义开头的字符
+		}
+		if !strings.Contains(strings.TrimSuffix(line, "/"), "/") {
+			line = "**/" + line
+		}
+
+		rule.pattern, err = compileGlob(line, g.opts.CaseInsensitive)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// escapeGlob 转义glob中的特殊字符，使其按字面匹配
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
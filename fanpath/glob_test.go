@@ -0,0 +1,211 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// relPaths strips root from every path and converts to slash form.
+func relPaths(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	return rels
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/app/main.go", true},
+		{"cmd/**", "cmd/app/main.go", true},
+		{"cmd/**/main.go", "cmd/main.go", true},
+		{"cmd/**/main.go", "cmd/a/b/main.go", true},
+		{"cmd/**/main.go", "pkg/main.go", false},
+		{"a/**/**/b", "a/b", true},
+		{"[a-c]?.txt", "b1.txt", true},
+	}
+	for _, tt := range tests {
+		p, err := compileGlob(tt.pattern, false)
+		if err != nil {
+			t.Fatalf("compileGlob(%q) failed: %v", tt.pattern, err)
+		}
+		if got := p.match(tt.name, false); got != tt.want {
+			t.Errorf("match(%q, %q) = %v; want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := compileGlob("a/[b", false); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"main.go", "README.MD", "cmd/app/main.go", "cmd/app/main_test.go", "vendor/lib/lib.go", "docs/guide.md"} {
+		writeTestFile(t, root, rel, rel)
+	}
+
+	tests := []struct {
+		patterns []string
+		opts     GlobOptions
+		want     []string
+	}{
+		{[]string{"**/*.go", "!vendor", "!**/*_test.go"}, GlobOptions{}, []string{"cmd/app/main.go", "main.go"}},
+		{[]string{"*.go"}, GlobOptions{}, []string{"main.go"}},
+		{[]string{"**/*.md"}, GlobOptions{}, []string{"docs/guide.md"}},
+		{[]string{"**/*.md"}, GlobOptions{CaseInsensitive: true}, []string{"README.MD", "docs/guide.md"}},
+		{[]string{"cmd/**", "!**/*.go"}, GlobOptions{IncludeDirs: true}, []string{"cmd", "cmd/app"}},
+		{[]string{"**/*.go"}, GlobOptions{FileFilter: func(name string) bool { return !strings.HasSuffix(name, "_test.go") }}, []string{"cmd/app/main.go", "main.go", "vendor/lib/lib.go"}},
+	}
+	for _, tt := range tests {
+		got, err := GlobWithOptions(root, tt.opts, tt.patterns...)
+		if err != nil {
+			t.Fatalf("Glob(%v) failed: %v", tt.patterns, err)
+		}
+		if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, tt.want) {
+			t.Errorf("Glob(%v, %+v) = %v; want %v", tt.patterns, tt.opts, rels, tt.want)
+		}
+	}
+}
+
+func TestGlobRootFileAndUnreadable(t *testing.T) {
+	root := t.TempDir()
+	file := writeTestFile(t, root, "a/b.txt", "b")
+	writeTestFile(t, root, "locked/c.txt", "c")
+
+	// A root that is a file is matched by its name.
+	if got, err := Glob(file, "**/*.txt"); err != nil || !reflect.DeepEqual(got, []string{file}) {
+		t.Errorf("Glob(file) = %v, %v; want [%s]", got, err, file)
+	}
+	if got, err := Glob(file, "*.md"); err != nil || len(got) != 0 {
+		t.Errorf("Glob(file, *.md) = %v, %v; want no match", got, err)
+	}
+
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("directory permissions are not enforced")
+	}
+	if _, err := Glob(root, "**/*.txt"); err == nil {
+		t.Error("Glob over an unreadable directory succeeded without SkipUnreadable")
+	}
+	got, err := GlobWithOptions(root, GlobOptions{SkipUnreadable: true}, "**/*.txt")
+	if err != nil {
+		t.Fatalf("Glob with SkipUnreadable failed: %v", err)
+	}
+	if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, []string{"a/b.txt"}) {
+		t.Errorf("Glob with SkipUnreadable = %v", rels)
+	}
+}
+
+func TestGlobIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, ".gitignore", "# build output\nbuild/\n*.log\n!keep.log\n/top.txt\n")
+	writeTestFile(t, root, "top.txt", "ignored at root only")
+	writeTestFile(t, root, "sub/top.txt", "kept")
+	writeTestFile(t, root, "sub/debug.log", "ignored")
+	writeTestFile(t, root, "sub/keep.log", "re-included")
+	writeTestFile(t, root, "build/out.bin", "ignored dir")
+	writeTestFile(t, root, "sub/.gitignore", "local.txt\n")
+	writeTestFile(t, root, "sub/local.txt", "ignored by nested file")
+	writeTestFile(t, root, "local.txt", "nested rules do not apply here")
+
+	got, err := GlobWithOptions(root, GlobOptions{IgnoreFiles: []string{".gitignore"}}, "!**/.gitignore")
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	want := []string{"local.txt", "sub/keep.log", "sub/top.txt"}
+	if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, want) {
+		t.Errorf("Glob = %v; want %v", rels, want)
+	}
+}
+
+func TestGetFileListByExt(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"a.txt", "b.tar.gz", "sub/c.txt", "sub/noext", "weird[1].txt"} {
+		writeTestFile(t, root, rel, rel)
+	}
+
+	tests := map[string][]string{
+		".txt":    {"a.txt", "sub/c.txt", "weird[1].txt"},
+		".tar.gz": {"b.tar.gz"},
+		"":        {"sub/noext"},
+	}
+	for ext, want := range tests {
+		got, err := GetFileListByExt(root, ext)
+		if err != nil {
+			t.Fatalf("GetFileListByExt(%q) failed: %v", ext, err)
+		}
+		if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, want) {
+			t.Errorf("GetFileListByExt(%q) = %v; want %v", ext, rels, want)
+		}
+	}
+
+	if err := InitDirAndClearGlob(root, "**/*.txt"); err != nil {
+		t.Fatalf("InitDirAndClearGlob failed: %v", err)
+	}
+	if ExistFile(filepath.Join(root, "sub", "c.txt")) || !ExistFile(filepath.Join(root, "b.tar.gz")) {
+		t.Error("InitDirAndClearGlob removed the wrong files")
+	}
+}
+
+func TestGetFileListByExtEdgeCases(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"v1.2/noext", "v1.2/a.gz", "bigz", "locked/secret.gz"} {
+		writeTestFile(t, root, rel, rel)
+	}
+
+	// Extensionless files under dotted directories are still listed.
+	got, err := GetFileListByExt(root, "")
+	if err != nil {
+		t.Fatalf(`GetFileListByExt("") failed: %v`, err)
+	}
+	if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, []string{"bigz", "v1.2/noext"}) {
+		t.Errorf(`GetFileListByExt("") = %v`, rels)
+	}
+
+	// An extension without the leading dot matches nothing.
+	if got, err := GetFileListByExt(root, "gz"); err != nil || len(got) != 0 {
+		t.Errorf(`GetFileListByExt("gz") = %v, %v; want no files`, got, err)
+	}
+
+	// A root that is a file is matched on its own name.
+	file := filepath.Join(root, "v1.2", "a.gz")
+	if got, err := GetFileListByExt(file, ".gz"); err != nil || !reflect.DeepEqual(got, []string{file}) {
+		t.Errorf("GetFileListByExt(file) = %v, %v; want [%s]", got, err, file)
+	}
+
+	// Unreadable subdirectories are skipped.
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("directory permissions are not enforced")
+	}
+	got, err = GetFileListByExt(root, ".gz")
+	if err != nil {
+		t.Fatalf("GetFileListByExt with an unreadable subdirectory failed: %v", err)
+	}
+	if rels := relPaths(t, root, got); !reflect.DeepEqual(rels, []string{"v1.2/a.gz"}) {
+		t.Errorf(`GetFileListByExt(".gz") = %v`, rels)
+	}
+}
@@ -0,0 +1,350 @@
+package fanpath
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HashAlgo 哈希算法
+type HashAlgo int
+
+const (
+	HashSHA256 HashAlgo = iota // 默认
+	HashSHA1
+	HashSHA512
+	HashMD5
+)
+
+func (a HashAlgo) String() string {
+	switch a {
+	case HashSHA256:
+		return "sha256"
+	case HashSHA1:
+		return "sha1"
+	case HashSHA512:
+		return "sha512"
+	case HashMD5:
+		return "md5"
+	default:
+		return fmt.Sprintf("HashAlgo(%d)", int(a))
+	}
+}
+
+// newHash 创建算法对应的hash.Hash
+func (a HashAlgo) newHash() (hash.Hash, error) {
+	switch a {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %v", a)
+	}
+}
+
+// HashFile 计算文件内容的哈希，返回十六进制字符串
+func HashFile(path string, algo HashAlgo) (string, error) {
+	sum, err := hashFileBytes(path, algo)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// hashFileBytes 计算文件内容的哈希
+func hashFileBytes(path string, algo HashAlgo) ([]byte, error) {
+	h, err := algo.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// HashOptions 计算目录哈希、比较目录和查找重复文件的选项
+type HashOptions struct {
+	Algo    HashAlgo // 哈希算法
+	Include []string // glob规则，规则同CopyOptions.Include
+	Exclude []string // glob规则，规则同CopyOptions.Exclude
+	Workers int      // 并行计算哈希的worker数量，小于等于0时使用CPU核数
+}
+
+// DirHash 目录的哈希
+type DirHash struct {
+	Digest string            // 整个目录树的Merkle哈希
+	Files  map[string]string // 以/分隔的相对路径到文件内容哈希的映射
+}
+
+// HashDir 计算目录树的Merkle哈希：文件的哈希为内容哈希，目录的哈希由按名称排序的子项名称、类型和哈希计算得到
+// 结果只取决于目录结构和文件内容，与修改时间、权限以及遍历顺序无关；符号链接不跟随，按链接内容计算
+// 目录只通过其中包含的文件和符号链接出现在树中，空目录和内容全部被过滤掉的目录不影响结果
+func HashDir(root string, opts HashOptions) (*DirHash, error) {
+	files, links, err := listHashTree(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sums, err := hashFiles(root, files, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DirHash{Files: make(map[string]string, len(files))}
+	tree := &hashNode{kind: 'd', children: make(map[string]*hashNode)}
+	for i, rel := range files {
+		result.Files[rel] = hex.EncodeToString(sums[i])
+		tree.add(rel, &hashNode{kind: 'f', sum: sums[i]})
+	}
+	for rel, target := range links {
+		tree.add(rel, &hashNode{kind: 'l', sum: []byte(target)})
+	}
+
+	digest, err := tree.digest(opts.Algo)
+	if err != nil {
+		return nil, err
+	}
+	result.Digest = hex.EncodeToString(digest)
+	return result, nil
+}
+
+// listHashTree 按过滤规则列出root下的文件和符号链接（相对路径到链接内容）
+func listHashTree(root string, opts HashOptions) (files []string, links map[string]string, err error) {
+	filter, err := newCopyFilter(CopyOptions{Include: opts.Include, Exclude: opts.Exclude})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	links = make(map[string]string)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if filter.excluded(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case d.IsDir(), !filter.included(rel):
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			links[rel] = filepath.ToSlash(target)
+		case d.Type().IsRegular():
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, links, err
+}
+
+// hashFiles 使用worker池计算root下各文件的哈希，结果与rels一一对应
+func hashFiles(root string, rels []string, opts HashOptions) ([][]byte, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	sums := make([][]byte, len(rels))
+	errs := make([]error, len(rels))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				sums[j], errs[j] = hashFileBytes(filepath.Join(root, filepath.FromSlash(rels[j])), opts.Algo)
+			}
+		}()
+	}
+	for i := range rels {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rels[i], err)
+		}
+	}
+	return sums, nil
+}
+
+// hashNode Merkle树的节点
+type hashNode struct {
+	kind     byte // 'f'文件，'l'符号链接，'d'目录
+	sum      []byte
+	children map[string]*hashNode
+}
+
+// add 在树中添加相对路径为rel的文件或符号链接节点，缺少的中间目录会被创建
+func (n *hashNode) add(rel string, node *hashNode) {
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := n.children[part]
+		if !ok {
+			child = &hashNode{kind: 'd', children: make(map[string]*hashNode)}
+			n.children[part] = child
+		}
+		n = child
+	}
+	n.children[parts[len(parts)-1]] = node
+}
+
+// digest 计算节点的哈希，文件节点直接返回内容哈希
+func (n *hashNode) digest(algo HashAlgo) ([]byte, error) {
+	if n.kind != 'd' {
+		return n.sum, nil
+	}
+
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h, err := algo.newHash()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		child := n.children[name]
+		sum, err := child.digest(algo)
+		if err != nil {
+			return nil, err
+		}
+		// 类型、名称和哈希之间用0分隔，避免不同的拼接产生相同的输入
+		h.Write([]byte{child.kind})
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(sum)
+		h.Write([]byte{0})
+	}
+	return h.Sum(nil), nil
+}
+
+// DirDiff 两个目录的差异，路径均为以/分隔的相对路径
+type DirDiff struct {
+	Added   []string // 只存在于b中的文件
+	Removed []string // 只存在于a中的文件
+	Changed []string // 两边都存在但内容不同的文件
+}
+
+// Equal 两个目录的文件是否完全相同
+func (d *DirDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffDirs 按内容比较目录a和b中的文件
+func DiffDirs(a, b string, opts HashOptions) (*DirDiff, error) {
+	hashA, err := HashDir(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	hashB, err := HashDir(b, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &DirDiff{}
+	for rel, sumA := range hashA.Files {
+		sumB, ok := hashB.Files[rel]
+		if !ok {
+			diff.Removed = append(diff.Removed, rel)
+		} else if sumA != sumB {
+			diff.Changed = append(diff.Changed, rel)
+		}
+	}
+	for rel := range hashB.Files {
+		if _, ok := hashA.Files[rel]; !ok {
+			diff.Added = append(diff.Added, rel)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
+
+// FindDuplicates 查找root下内容相同的文件，返回以/分隔的相对路径分组，每组至少两个文件
+// 先按大小分组，只对大小相同的文件计算哈希；组内和组之间都按路径排序
+func FindDuplicates(root string, opts HashOptions) ([][]string, error) {
+	files, _, err := listHashTree(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bySize := make(map[int64][]string)
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], rel)
+	}
+	var candidates []string
+	for _, rels := range bySize {
+		if len(rels) > 1 {
+			candidates = append(candidates, rels...)
+		}
+	}
+
+	sums, err := hashFiles(root, candidates, opts)
+	if err != nil {
+		return nil, err
+	}
+	bySum := make(map[string][]string)
+	for i, rel := range candidates {
+		key := hex.EncodeToString(sums[i])
+		bySum[key] = append(bySum[key], rel)
+	}
+
+	var groups [][]string
+	for _, rels := range bySum {
+		if len(rels) > 1 {
+			sort.Strings(rels)
+			groups = append(groups, rels)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	return groups, nil
+}
@@ -0,0 +1,128 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHashFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "abc.txt", "abc")
+
+	want := map[HashAlgo]string{
+		HashMD5:    "900150983cd24fb0d6963f7d28e17f72",
+		HashSHA1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		HashSHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}
+	for algo, sum := range want {
+		got, err := HashFile(path, algo)
+		if err != nil || got != sum {
+			t.Errorf("HashFile(%v) = %s, %v; want %s", algo, got, err, sum)
+		}
+	}
+	if _, err := HashFile(path, HashAlgo(99)); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	build := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for rel, content := range files {
+			writeTestFile(t, dir, rel, content)
+		}
+		return dir
+	}
+	base := map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/c.log": "log"}
+
+	a, err := HashDir(build(t, base), HashOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("HashDir failed: %v", err)
+	}
+	b, err := HashDir(build(t, base), HashOptions{})
+	if err != nil {
+		t.Fatalf("HashDir failed: %v", err)
+	}
+	if a.Digest != b.Digest || len(a.Files) != 3 {
+		t.Errorf("identical trees hash differently: %s vs %s (%d files)", a.Digest, b.Digest, len(a.Files))
+	}
+
+	// Moving a file to another directory changes the digest even though the
+	// set of contents stays the same.
+	moved, err := HashDir(build(t, map[string]string{"a.txt": "a", "b.txt": "b", "sub/c.log": "log"}), HashOptions{})
+	if err != nil {
+		t.Fatalf("HashDir failed: %v", err)
+	}
+	if moved.Digest == a.Digest {
+		t.Error("moving a file did not change the digest")
+	}
+
+	// Filters are honoured.
+	filtered, err := HashDir(build(t, map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/c.log": "other"}), HashOptions{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("HashDir failed: %v", err)
+	}
+	unfiltered, _ := HashDir(build(t, base), HashOptions{Exclude: []string{"*.log"}})
+	if filtered.Digest != unfiltered.Digest {
+		t.Error("excluded file affected the digest")
+	}
+
+	// Empty directories and directories whose files are all excluded do not
+	// contribute to the digest.
+	withEmpty := build(t, base)
+	if err := os.MkdirAll(filepath.Join(withEmpty, "empty", "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, withEmpty, "logs/only.log", "log")
+	got, err := HashDir(withEmpty, HashOptions{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("HashDir failed: %v", err)
+	}
+	if got.Digest != unfiltered.Digest {
+		t.Error("empty or fully excluded directories affected the digest")
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeTestFile(t, a, "same.txt", "same")
+	writeTestFile(t, b, "same.txt", "same")
+	writeTestFile(t, a, "sub/changed.txt", "old")
+	writeTestFile(t, b, "sub/changed.txt", "new")
+	writeTestFile(t, a, "removed.txt", "gone")
+	writeTestFile(t, b, "added.txt", "new")
+
+	diff, err := DiffDirs(a, b, HashOptions{})
+	if err != nil {
+		t.Fatalf("DiffDirs failed: %v", err)
+	}
+	want := &DirDiff{Added: []string{"added.txt"}, Removed: []string{"removed.txt"}, Changed: []string{"sub/changed.txt"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffDirs = %+v; want %+v", diff, want)
+	}
+	if diff.Equal() {
+		t.Error("Equal reported true for differing trees")
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "dup")
+	writeTestFile(t, dir, "sub/b.txt", "dup")
+	writeTestFile(t, dir, "sub/c.txt", "dup")
+	writeTestFile(t, dir, "d.txt", "xyz") // same size, different content
+	writeTestFile(t, dir, "e.txt", "unique")
+	writeTestFile(t, dir, "x/1.bin", "pair")
+	writeTestFile(t, dir, "x/2.bin", "pair")
+
+	groups, err := FindDuplicates(dir, HashOptions{Workers: 3})
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	want := [][]string{{"a.txt", "sub/b.txt", "sub/c.txt"}, {"x/1.bin", "x/2.bin"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("FindDuplicates = %v; want %v", groups, want)
+	}
+}
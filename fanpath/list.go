@@ -0,0 +1,256 @@
+package fanpath
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey 列出目录时的排序方式
+type SortKey int
+
+const (
+	SortByName        SortKey = iota // 按相对路径的字节序，默认
+	SortByNaturalName                // 按相对路径的自然顺序，数字按数值比较，如file2在file10之前，不区分大小写
+	SortBySize                       // 按大小
+	SortByModTime                    // 按修改时间
+	SortByExt                        // 按扩展名（不区分大小写），相同时按名称
+)
+
+func (k SortKey) String() string {
+	switch k {
+	case SortByName:
+		return "name"
+	case SortByNaturalName:
+		return "natural"
+	case SortBySize:
+		return "size"
+	case SortByModTime:
+		return "mtime"
+	case SortByExt:
+		return "ext"
+	default:
+		return fmt.Sprintf("SortKey(%d)", int(k))
+	}
+}
+
+// EntryTypes 列出的条目类型，可以组合，0表示所有类型
+type EntryTypes int
+
+const (
+	ListFiles    EntryTypes = 1 << iota // 目录和符号链接之外的条目
+	ListDirs                            // 目录
+	ListSymlinks                        // 符号链接，不跟随
+)
+
+// ListOptions 列出目录的选项
+type ListOptions struct {
+	Sort            SortKey       // 排序方式，相同时按相对路径排序
+	Descending      bool          // 降序
+	MaxDepth        int           // 最大深度，规则同WalkOptions.MaxDepth：dir的直接子项深度为1，0表示不限制
+	Types           EntryTypes    // 条目类型，0表示所有类型
+	MinSize         int64         // 只列出大小不小于MinSize的文件，不影响目录
+	MaxSize         int64         // 大于0时只列出大小不超过MaxSize的文件，不影响目录
+	MinAge          time.Duration // 大于0时只列出修改时间早于MinAge之前的条目
+	MaxAge          time.Duration // 大于0时只列出修改时间在MaxAge之内的条目
+	ContinueOnError bool          // 读取某一项出错时跳过并继续，错误合并后与结果一起返回
+}
+
+// ListEntry 列出的一项
+type ListEntry struct {
+	Path  string      // 包含dir前缀的路径
+	Rel   string      // 以/分隔的相对路径
+	Depth int         // 深度，dir的直接子项为1
+	Info  fs.FileInfo // 条目自身的信息，符号链接不跟随
+}
+
+// List 列出dir下符合条件的条目并排序，符号链接指向的目录不会展开
+// ContinueOnError为true时，无法读取的目录或条目被跳过，返回的error为各项错误的合并，结果仍然有效
+func List(dir string, opts ListOptions) ([]ListEntry, error) {
+	l := &lister{root: dir, opts: opts, now: time.Now()}
+	if err := l.list(dir, "", 1); err != nil {
+		return nil, err
+	}
+	sortListEntries(l.entries, opts.Sort, opts.Descending)
+	return l.entries, errors.Join(l.errs...)
+}
+
+// lister List的遍历状态
+type lister struct {
+	root    string
+	opts    ListOptions
+	now     time.Time
+	entries []ListEntry
+	errs    []error
+}
+
+// fail 处理错误，ContinueOnError时记录后继续
+func (l *lister) fail(err error) error {
+	if !l.opts.ContinueOnError {
+		return err
+	}
+	l.errs = append(l.errs, err)
+	return nil
+}
+
+// list 读取dirPath中深度为depth的条目
+func (l *lister) list(dirPath, rel string, depth int) error {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil && len(dirEntries) == 0 {
+		return l.fail(err)
+	}
+	if err != nil {
+		// 读取了一部分时保留已读取的条目
+		if err := l.fail(err); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range dirEntries {
+		entryPath := filepath.Join(dirPath, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			// 读取目录后被删除的条目直接忽略
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err := l.fail(err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if l.accept(info) {
+			l.entries = append(l.entries, ListEntry{Path: entryPath, Rel: entryRel, Depth: depth, Info: info})
+		}
+		if info.IsDir() && (l.opts.MaxDepth <= 0 || depth < l.opts.MaxDepth) {
+			if err := l.list(entryPath, entryRel, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// accept 条目是否符合类型、大小和时间的过滤条件
+func (l *lister) accept(info fs.FileInfo) bool {
+	var kind EntryTypes
+	switch {
+	case info.IsDir():
+		kind = ListDirs
+	case info.Mode()&fs.ModeSymlink != 0:
+		kind = ListSymlinks
+	default:
+		kind = ListFiles
+	}
+	if l.opts.Types != 0 && l.opts.Types&kind == 0 {
+		return false
+	}
+
+	if kind != ListDirs {
+		if info.Size() < l.opts.MinSize || (l.opts.MaxSize > 0 && info.Size() > l.opts.MaxSize) {
+			return false
+		}
+	}
+	age := l.now.Sub(info.ModTime())
+	if l.opts.MinAge > 0 && age < l.opts.MinAge {
+		return false
+	}
+	if l.opts.MaxAge > 0 && age > l.opts.MaxAge {
+		return false
+	}
+	return true
+}
+
+// sortListEntries 按key排序，相同时按相对路径排序，保证结果确定
+func sortListEntries(entries []ListEntry, key SortKey, descending bool) {
+	compare := func(a, b ListEntry) int {
+		switch key {
+		case SortByNaturalName:
+			return naturalCompare(a.Rel, b.Rel)
+		case SortBySize:
+			return compareInt64(a.Info.Size(), b.Info.Size())
+		case SortByModTime:
+			return compareInt64(a.Info.ModTime().UnixNano(), b.Info.ModTime().UnixNano())
+		case SortByExt:
+			return strings.Compare(strings.ToLower(path.Ext(a.Rel)), strings.ToLower(path.Ext(b.Rel)))
+		default:
+			return 0
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		c := compare(entries[i], entries[j])
+		if c == 0 {
+			c = strings.Compare(entries[i].Rel, entries[j].Rel)
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// naturalCompare 按自然顺序比较字符串：连续的数字按数值比较，其余字符不区分大小写
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numA, restA := splitDigits(a)
+			numB, restB := splitDigits(b)
+			// 去掉前导0后先比较长度再比较字面，避免溢出
+			trimA, trimB := strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+			if len(trimA) != len(trimB) {
+				return compareInt64(int64(len(trimA)), int64(len(trimB)))
+			}
+			if c := strings.Compare(trimA, trimB); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+
+		ca, cb := lowerASCII(a[0]), lowerASCII(b[0])
+		if ca != cb {
+			return compareInt64(int64(ca), int64(cb))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInt64(int64(len(a)), int64(len(b)))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// splitDigits 把s分为开头的数字和其余部分
+func splitDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
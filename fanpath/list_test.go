@@ -0,0 +1,164 @@
+package fanpath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listRels(entries []ListEntry) []string {
+	rels := make([]string, 0, len(entries))
+	for _, e := range entries {
+		rels = append(rels, e.Rel)
+	}
+	return rels
+}
+
+func TestListSortKeys(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		rel     string
+		size    int
+		modTime time.Time
+	}{
+		{"file10.txt", 3, now.Add(-3 * time.Hour)},
+		{"file2.md", 1, now.Add(-1 * time.Hour)},
+		{"File1.go", 2, now.Add(-2 * time.Hour)},
+	}
+	for _, f := range files {
+		p := writeTestFile(t, root, f.rel, strings.Repeat("x", f.size))
+		if err := os.Chtimes(p, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		opts ListOptions
+		want []string
+	}{
+		{ListOptions{}, []string{"File1.go", "file10.txt", "file2.md"}},
+		{ListOptions{Sort: SortByNaturalName}, []string{"File1.go", "file2.md", "file10.txt"}},
+		{ListOptions{Sort: SortByNaturalName, Descending: true}, []string{"file10.txt", "file2.md", "File1.go"}},
+		{ListOptions{Sort: SortBySize}, []string{"file2.md", "File1.go", "file10.txt"}},
+		{ListOptions{Sort: SortByModTime}, []string{"file10.txt", "File1.go", "file2.md"}},
+		{ListOptions{Sort: SortByExt}, []string{"File1.go", "file2.md", "file10.txt"}},
+		{ListOptions{MinSize: 2, MaxSize: 2}, []string{"File1.go"}},
+		{ListOptions{MaxAge: 90 * time.Minute}, []string{"file2.md"}},
+		{ListOptions{MinAge: 150 * time.Minute}, []string{"file10.txt"}},
+	}
+	for _, c := range cases {
+		entries, err := List(root, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := listRels(entries); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("List(%+v) = %v; want %v", c.opts, got, c.want)
+		}
+	}
+}
+
+func TestListDepthAndTypes(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "a.txt", "a")
+	writeTestFile(t, root, "sub/b.txt", "b")
+	writeTestFile(t, root, "sub/deep/c.txt", "c")
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("sub", filepath.Join(root, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := List(root, ListOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.txt", "sub"}
+	if runtime.GOOS != "windows" {
+		want = []string{"a.txt", "link", "sub"}
+	}
+	if got := listRels(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("MaxDepth 1 = %v; want %v", got, want)
+	}
+
+	entries, err = List(root, ListOptions{Types: ListFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listRels(entries), []string{"a.txt", "sub/b.txt", "sub/deep/c.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v; want %v", got, want)
+	}
+	for _, e := range entries {
+		if e.Depth != strings.Count(e.Rel, "/")+1 || e.Path != filepath.Join(root, filepath.FromSlash(e.Rel)) {
+			t.Errorf("entry %+v has wrong depth or path", e)
+		}
+	}
+
+	entries, err = List(root, ListOptions{Types: ListDirs | ListSymlinks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := listRels(entries)
+	if !sort.StringsAreSorted(got) || len(got) < 2 || got[len(got)-1] != "sub/deep" {
+		t.Errorf("dirs and symlinks = %v", got)
+	}
+}
+
+func TestListContinueOnError(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("requires unreadable directories")
+	}
+	root := t.TempDir()
+	writeTestFile(t, root, "ok/a.txt", "a")
+	writeTestFile(t, root, "locked/b.txt", "b")
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	if _, err := List(root, ListOptions{}); err == nil {
+		t.Error("List should fail on an unreadable directory")
+	}
+	entries, err := List(root, ListOptions{Types: ListFiles, ContinueOnError: true})
+	if err == nil {
+		t.Error("ContinueOnError should still report the error")
+	}
+	if got, want := listRels(entries), []string{"ok/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v; want %v", got, want)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	names := []string{"img12.png", "img10.png", "IMG2.png", "img1.png", "img002.png", "a", "img"}
+	sort.Slice(names, func(i, j int) bool { return naturalCompare(names[i], names[j]) < 0 })
+	want := []string{"a", "img", "img1.png", "IMG2.png", "img002.png", "img10.png", "img12.png"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("natural order = %v; want %v", names, want)
+	}
+}
+
+func TestGetFileListByModTimeUsesList(t *testing.T) {
+	root := t.TempDir()
+	old := writeTestFile(t, root, "old.txt", "o")
+	writeTestFile(t, root, "new.txt", "n")
+	writeTestFile(t, root, "sub/skip.txt", "s")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetFileListByModTime(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{old, filepath.Join(root, "new.txt")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFileListByModTime = %v; want %v", got, want)
+	}
+}
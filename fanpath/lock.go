@@ -0,0 +1,278 @@
+package fanpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockMode 锁的类型
+type LockMode int
+
+const (
+	LockExclusive LockMode = iota // 排他锁（写），默认
+	LockShared                    // 共享锁（读），可以被多个持有者同时持有，与排他锁互斥
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case LockExclusive:
+		return "exclusive"
+	case LockShared:
+		return "shared"
+	default:
+		return fmt.Sprintf("LockMode(%d)", int(m))
+	}
+}
+
+// ErrLocked 锁已被其他持有者占用
+var ErrLocked = errors.New("path is locked")
+
+const (
+	lockPollMin = 5 * time.Millisecond   // 等待锁时的初始重试间隔
+	lockPollMax = 100 * time.Millisecond // 等待锁时的最大重试间隔
+)
+
+// FileLock 已获得的跨进程锁，通过Unlock释放
+type FileLock struct {
+	path   string
+	mode   LockMode
+	mu     sync.Mutex
+	unlock func() error // 为nil表示已经释放
+}
+
+// Path 锁文件的路径
+func (l *FileLock) Path() string {
+	return l.path
+}
+
+// Mode 锁的类型
+func (l *FileLock) Mode() LockMode {
+	return l.mode
+}
+
+// Unlock 释放锁，重复调用时什么也不做
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unlock == nil {
+		return nil
+	}
+	err := l.unlock()
+	l.unlock = nil
+	return err
+}
+
+// lockFilePath path对应的锁文件，文件和目录都使用同级的<path>.lock，不会修改被锁的路径本身
+// 空路径和文件系统根目录没有可以放置锁文件的同级位置，返回错误
+func lockFilePath(path string) (string, error) {
+	clean := filepath.Clean(path)
+	if strings.TrimSpace(path) == "" || (filepath.Dir(clean) == clean && clean != ".") {
+		return "", fmt.Errorf("cannot lock %q: no sibling path for the lock file", path)
+	}
+	return strings.TrimRight(path, `/\`) + ".lock", nil
+}
+
+// Lock 获取path的跨进程锁，阻塞直到成功
+// unix上使用flock锁定<path>.lock，进程退出时锁由系统自动释放；
+// 其他平台使用以O_EXCL创建的<path>.lock作为锁文件，持有进程已经退出的锁文件会被清理，共享锁退化为排他锁
+func Lock(path string, mode LockMode) (*FileLock, error) {
+	lockPath, err := lockFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return lockBlocking(lockPath, mode)
+}
+
+// TryLock 尝试获取path的跨进程锁，被占用时立即返回ErrLocked
+func TryLock(path string, mode LockMode) (*FileLock, error) {
+	lockPath, err := lockFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return tryLock(lockPath, mode)
+}
+
+// LockWithTimeout 获取path的跨进程锁，超过timeout仍未成功时返回包装了ErrLocked的错误
+func LockWithTimeout(path string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return LockContext(ctx, path, mode)
+}
+
+// LockContext 获取path的跨进程锁，ctx取消时返回包装了ErrLocked和ctx.Err()的错误
+func LockContext(ctx context.Context, path string, mode LockMode) (*FileLock, error) {
+	lockPath, err := lockFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return pollLock(ctx, lockPath, mode, tryLock)
+}
+
+// pollLock 以逐渐增大的间隔重试try，直到成功、出现其他错误或ctx取消
+func pollLock(ctx context.Context, lockPath string, mode LockMode, try func(string, LockMode) (*FileLock, error)) (*FileLock, error) {
+	wait := lockPollMin
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		l, err := try(lockPath, mode)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %s: %w", ErrLocked, lockPath, ctx.Err())
+		case <-timer.C:
+		}
+		if wait *= 2; wait > lockPollMax {
+			wait = lockPollMax
+		}
+	}
+}
+
+// lockOwner 锁文件回退方案中写入锁文件的持有者信息
+type lockOwner struct {
+	pid  int
+	host string
+}
+
+func (o lockOwner) String() string {
+	return strconv.Itoa(o.pid) + "\n" + o.host + "\n"
+}
+
+// parseLockOwner 解析锁文件的内容
+func parseLockOwner(data string) (lockOwner, bool) {
+	lines := strings.Split(data, "\n")
+	if len(lines) < 2 {
+		return lockOwner{}, false
+	}
+	pid, err := strconv.Atoi(lines[0])
+	if err != nil || pid <= 0 {
+		return lockOwner{}, false
+	}
+	return lockOwner{pid: pid, host: lines[1]}, true
+}
+
+// currentLockOwner 当前进程的持有者信息
+func currentLockOwner() lockOwner {
+	host, _ := os.Hostname()
+	return lockOwner{pid: os.Getpid(), host: host}
+}
+
+// staleEmptyLockAge 内容无法解析的锁文件超过这个时间后视为创建者在写入前崩溃留下的
+const staleEmptyLockAge = 5 * time.Second
+
+// tryLockFile 锁文件回退方案：以O_EXCL创建锁文件并写入持有者，所有模式都按排他锁处理
+// 锁文件的持有者在本机且进程已经不存在时，锁文件会被清理后重试一次，见removeStaleLock
+func tryLockFile(lockPath string, mode LockMode) (*FileLock, error) {
+	for attempt := 0; ; attempt++ {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			owner := currentLockOwner()
+			_, err = file.WriteString(owner.String())
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, err
+			}
+			return &FileLock{path: lockPath, mode: mode, unlock: func() error {
+				return unlockFile(lockPath, owner)
+			}}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if attempt > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, lockPath)
+		}
+		if !staleLockFile(lockPath) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, lockPath)
+		}
+		if err := removeStaleLock(lockPath); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unlockFile 释放锁文件回退方案中的锁
+// 锁文件可能已经被其他进程当作陈旧的锁接管（如pid被复用），直接删除lockPath会删掉别人的有效锁，
+// 因此同样先把它移到一边，确认内容仍是owner才删除，否则放回原处并返回错误
+func unlockFile(lockPath string, owner lockOwner) error {
+	moved, err := moveLockAside(lockPath)
+	if err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(moved); err == nil && string(data) == owner.String() {
+		return os.Remove(moved)
+	}
+	if err := restoreLockFile(moved, lockPath); err != nil {
+		return err
+	}
+	return fmt.Errorf("lock file %s was taken over by another process", lockPath)
+}
+
+// removeStaleLock 清理已经判断为陈旧的锁文件
+// 判断之后锁文件可能已经被其他进程清理并重新创建，直接删除lockPath会删掉别人的有效锁，
+// 因此先把lockPath原子地重命名为唯一的名称，对移走的文件重新判断一次，仍然陈旧才删除，否则把它放回原处
+// 重命名失败说明其他进程已经清理，无论哪种情况都由之后的O_EXCL创建决定归属；只有无法放回有效的锁时返回错误
+func removeStaleLock(lockPath string) error {
+	moved, err := moveLockAside(lockPath)
+	if err != nil {
+		return nil
+	}
+	if staleLockFile(moved) {
+		os.Remove(moved)
+		return nil
+	}
+	return restoreLockFile(moved, lockPath)
+}
+
+// moveLockAside 把锁文件原子地重命名为同目录下唯一的名称，返回新的路径
+func moveLockAside(lockPath string) (string, error) {
+	moved := fmt.Sprintf("%s.%d.%d.moved", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, moved); err != nil {
+		return "", err
+	}
+	return moved, nil
+}
+
+// restoreLockFile 把移到一边的有效锁文件放回lockPath，放回成功后才删除moved
+// 优先使用硬链接，它不会覆盖期间新建的锁文件；文件系统不支持硬链接时退回到重命名
+// 期间已经有进程创建了新的锁文件时无法放回，保留moved并返回错误
+func restoreLockFile(moved, lockPath string) error {
+	err := os.Link(moved, lockPath)
+	if err == nil {
+		return os.Remove(moved)
+	}
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("cannot restore live lock file %s, kept as %s: %w", lockPath, moved, err)
+	}
+	return os.Rename(moved, lockPath)
+}
+
+// staleLockFile 锁文件的持有者是否已经不存在
+func staleLockFile(lockPath string) bool {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	owner, ok := parseLockOwner(string(data))
+	if !ok {
+		info, err := os.Stat(lockPath)
+		return err == nil && time.Since(info.ModTime()) > staleEmptyLockAge
+	}
+	// 其他主机上的进程无法确认是否存活
+	return owner.host == currentLockOwner().host && !processAlive(owner.pid)
+}
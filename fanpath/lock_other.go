@@ -0,0 +1,26 @@
+//go:build !unix
+
+package fanpath
+
+import (
+	"context"
+	"os"
+)
+
+func tryLock(lockPath string, mode LockMode) (*FileLock, error) {
+	return tryLockFile(lockPath, mode)
+}
+
+func lockBlocking(lockPath string, mode LockMode) (*FileLock, error) {
+	return pollLock(context.Background(), lockPath, mode, tryLockFile)
+}
+
+// processAlive 进程是否存在，windows上FindProcess需要打开进程句柄，进程不存在时返回错误
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
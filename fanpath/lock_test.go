@@ -0,0 +1,227 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockExclusive(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+
+	l, err := Lock(dir, LockExclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Path() != dir+".lock" {
+		t.Errorf("Path() = %s", l.Path())
+	}
+	if _, err := TryLock(dir, LockExclusive); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock exclusive = %v; want ErrLocked", err)
+	}
+	if _, err := TryLock(dir, LockShared); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock shared = %v; want ErrLocked", err)
+	}
+
+	start := time.Now()
+	if _, err := LockWithTimeout(dir, LockExclusive, 50*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("LockWithTimeout = %v; want ErrLocked", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("LockWithTimeout returned before the timeout")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		l.Unlock()
+	}()
+	l2, err := LockWithTimeout(dir, LockExclusive, 5*time.Second)
+	if err != nil {
+		t.Fatalf("LockWithTimeout after unlock: %v", err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Errorf("second Unlock = %v", err)
+	}
+}
+
+func TestLockShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+
+	r1, err := TryLock(path, LockShared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := TryLock(path, LockShared)
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			t.Skip("shared locks are exclusive on this platform")
+		}
+		t.Fatal(err)
+	}
+	if _, err := TryLock(path, LockExclusive); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock exclusive with readers = %v; want ErrLocked", err)
+	}
+
+	r1.Unlock()
+	if _, err := TryLock(path, LockExclusive); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock exclusive with one reader = %v; want ErrLocked", err)
+	}
+	r2.Unlock()
+	w, err := TryLock(path, LockExclusive)
+	if err != nil {
+		t.Fatalf("TryLock exclusive after readers left: %v", err)
+	}
+	w.Unlock()
+}
+
+func TestLockFileFallback(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "x.lock")
+
+	l, err := tryLockFile(lockPath, LockShared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tryLockFile(lockPath, LockShared); !errors.Is(err, ErrLocked) {
+		t.Errorf("second tryLockFile = %v; want ErrLocked", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if ExistPath(lockPath) {
+		t.Error("lock file was not removed")
+	}
+
+	// A lock file left behind by a dead process on this host is stale.
+	dead := lockOwner{pid: 1 << 30, host: currentLockOwner().host}
+	if processAlive(dead.pid) {
+		t.Skip("pid used for the dead owner exists")
+	}
+	if err := os.WriteFile(lockPath, []byte(dead.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err = tryLockFile(lockPath, LockExclusive)
+	if err != nil {
+		t.Fatalf("tryLockFile over stale lock: %v", err)
+	}
+	l.Unlock()
+
+	// Owners on other hosts and fresh unparsable files are left alone.
+	for _, content := range []string{lockOwner{pid: 1 << 30, host: "elsewhere"}.String(), ""} {
+		if err := os.WriteFile(lockPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tryLockFile(lockPath, LockExclusive); !errors.Is(err, ErrLocked) {
+			t.Errorf("tryLockFile over %q = %v; want ErrLocked", content, err)
+		}
+	}
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(lockPath, old, old)
+	if _, err := tryLockFile(lockPath, LockExclusive); err != nil {
+		t.Errorf("tryLockFile over old empty lock: %v", err)
+	}
+}
+
+func TestLockFileStaleTakeoverKeepsLiveLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "x.lock")
+	dead := lockOwner{pid: 1 << 30, host: currentLockOwner().host}
+	if processAlive(dead.pid) {
+		t.Skip("pid used for the dead owner exists")
+	}
+	if err := os.WriteFile(lockPath, []byte(dead.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !staleLockFile(lockPath) {
+		t.Fatal("lock file of a dead owner is not stale")
+	}
+
+	// Between the check and the cleanup another process removes the stale
+	// file and takes the lock; the late cleanup must not delete that lock.
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tryLockFile(lockPath, LockExclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	removeStaleLock(lockPath)
+
+	if _, err := tryLockFile(lockPath, LockExclusive); !errors.Is(err, ErrLocked) {
+		t.Errorf("tryLockFile after late cleanup = %v; want ErrLocked", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory contains %d entries; want only the live lock file", len(entries))
+	}
+}
+
+func TestLockRejectsRoot(t *testing.T) {
+	for _, path := range []string{"", string(filepath.Separator), filepath.VolumeName(os.TempDir()) + string(filepath.Separator)} {
+		if l, err := TryLock(path, LockExclusive); err == nil {
+			l.Unlock()
+			t.Errorf("TryLock(%q) succeeded; want an error", path)
+		}
+	}
+}
+
+func TestLockFileUnlockKeepsTakenOverLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "x.lock")
+	l, err := tryLockFile(lockPath, LockExclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer wrongly took the lock over as stale and now holds it.
+	peer := lockOwner{pid: os.Getpid() + 1, host: "peer"}.String()
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, []byte(peer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Unlock(); err == nil {
+		t.Error("Unlock of a taken-over lock succeeded")
+	}
+	if data, err := os.ReadFile(lockPath); err != nil || string(data) != peer {
+		t.Errorf("peer lock file after Unlock = %q, %v; want it untouched", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(lockPath)); len(entries) != 1 {
+		t.Errorf("directory contains %d entries; want only the peer lock file", len(entries))
+	}
+}
+
+func TestRestoreLockFileKeepsBothLocks(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "x.lock")
+	moved := lockPath + ".moved"
+	if err := os.WriteFile(moved, []byte("live"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, []byte("newer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lock cannot be put back over a newer one; neither file may be lost.
+	if err := restoreLockFile(moved, lockPath); err == nil {
+		t.Error("restoreLockFile over an existing lock succeeded")
+	}
+	if !ExistFile(moved) || !ExistFile(lockPath) {
+		t.Error("a live lock file was deleted")
+	}
+
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreLockFile(moved, lockPath); err != nil {
+		t.Fatalf("restoreLockFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(lockPath); string(data) != "live" || ExistFile(moved) {
+		t.Errorf("lock not restored: content %q, moved file left: %v", data, ExistFile(moved))
+	}
+}
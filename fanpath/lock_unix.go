@@ -0,0 +1,68 @@
+//go:build unix
+
+package fanpath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// flockHow LockMode对应的flock操作
+func flockHow(mode LockMode) int {
+	if mode == LockShared {
+		return syscall.LOCK_SH
+	}
+	return syscall.LOCK_EX
+}
+
+// openLockFile 打开（必要时创建）锁文件，锁文件在释放后保留，避免删除与加锁之间的竞争
+func openLockFile(lockPath string) (*os.File, error) {
+	return os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// flockFile 对锁文件加flock，nonblock为true时被占用返回ErrLocked
+func flockFile(lockPath string, mode LockMode, nonblock bool) (*FileLock, error) {
+	file, err := openLockFile(lockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	how := flockHow(mode)
+	if nonblock {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, lockPath)
+		}
+		return nil, &os.PathError{Op: "flock", Path: lockPath, Err: err}
+	}
+
+	return &FileLock{path: lockPath, mode: mode, unlock: func() error {
+		// 关闭文件即释放flock
+		return file.Close()
+	}}, nil
+}
+
+func tryLock(lockPath string, mode LockMode) (*FileLock, error) {
+	return flockFile(lockPath, mode, true)
+}
+
+func lockBlocking(lockPath string, mode LockMode) (*FileLock, error) {
+	return flockFile(lockPath, mode, false)
+}
+
+// processAlive 进程是否存在，没有权限发送信号的进程同样视为存在
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
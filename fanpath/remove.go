@@ -0,0 +1,254 @@
+package fanpath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrUnsafePath 拒绝删除文件系统根目录、用户主目录（及其上级目录）或允许范围之外的路径
+var ErrUnsafePath = errors.New("refusing to remove unsafe path")
+
+// RemoveOptions 删除文件时的保护选项
+type RemoveOptions struct {
+	AllowedBase string // 非空时只允许删除该目录之内的路径，不包括该目录自身；清空目录内容的操作允许就是该目录
+	TrashDir    string // 非空时把要删除的路径移动到该目录下以时间命名的子目录中，而不是直接删除
+	DryRun      bool   // 只返回将被删除的路径，不做任何修改
+}
+
+// CheckRemovable 检查路径是否可以安全地删除，不安全时返回包装了ErrUnsafePath的错误
+// 路径会先转为绝对路径并解析符号链接，因此指向根目录或主目录的链接同样会被拒绝
+func CheckRemovable(path string, opts RemoveOptions) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("%w: empty path", ErrUnsafePath)
+	}
+
+	for _, p := range candidatePaths(path) {
+		if filepath.Dir(p) == p {
+			return fmt.Errorf("%w: %s is a filesystem root", ErrUnsafePath, path)
+		}
+		if home, err := os.UserHomeDir(); err == nil && home != "" {
+			for _, h := range candidatePaths(home) {
+				// 主目录本身是根目录（部分容器中HOME=/）时已由上面的检查覆盖
+				if filepath.Dir(h) != h && (p == h || isWithin(p, h)) {
+					return fmt.Errorf("%w: %s contains the home directory", ErrUnsafePath, path)
+				}
+			}
+		}
+		if opts.AllowedBase != "" && !withinAny(p, candidatePaths(opts.AllowedBase)) {
+			return fmt.Errorf("%w: %s is outside %s", ErrUnsafePath, path, opts.AllowedBase)
+		}
+	}
+	return nil
+}
+
+// checkClearable 检查是否可以清空目录path中的内容，规则同CheckRemovable，但允许path就是AllowedBase
+func checkClearable(path string, opts RemoveOptions) error {
+	if opts.AllowedBase != "" && strings.TrimSpace(path) != "" {
+		bases := candidatePaths(opts.AllowedBase)
+		for _, p := range candidatePaths(path) {
+			for _, base := range bases {
+				if p == base {
+					opts.AllowedBase = ""
+				}
+			}
+		}
+	}
+	return CheckRemovable(path, opts)
+}
+
+// candidatePaths 路径的绝对路径，以及解析符号链接后的真实路径（存在且不同时）
+func candidatePaths(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return []string{filepath.Clean(path)}
+	}
+	paths := []string{abs}
+	if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+		paths = append(paths, real)
+	}
+	return paths
+}
+
+// isWithin child是否位于parent之内，不包括parent自身
+func isWithin(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// withinAny p是否位于任意一个目录之内
+func withinAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithin(dir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SafeRemoveAll 检查通过后删除路径及其内容，返回被删除（或DryRun时将被删除）的路径
+func SafeRemoveAll(path string, opts RemoveOptions) ([]string, error) {
+	if err := CheckRemovable(path, opts); err != nil {
+		return nil, err
+	}
+	if !ExistPath(path) {
+		return nil, nil
+	}
+	r, err := newRemover(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.remove(path, filepath.Base(path)); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+// ClearDirAndCreateNewWithOptions 检查通过后清空目录，目录不存在时创建，返回被删除（或DryRun时将被删除）的目录项
+// 与SafeRemoveAll不同，path可以就是AllowedBase；path是文件或符号链接时删除它后创建目录
+func ClearDirAndCreateNewWithOptions(path string, opts RemoveOptions) ([]string, error) {
+	if err := checkClearable(path, opts); err != nil {
+		return nil, err
+	}
+	r, err := newRemover(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			entryPath := filepath.Join(path, entry.Name())
+			if err := r.remove(entryPath, filepath.Join(filepath.Base(path), entry.Name())); err != nil {
+				return removed, err
+			}
+			removed = append(removed, entryPath)
+		}
+	default:
+		if err := r.remove(path, filepath.Base(path)); err != nil {
+			return nil, err
+		}
+		removed = append(removed, path)
+	}
+	if opts.DryRun {
+		return removed, nil
+	}
+	return removed, os.MkdirAll(path, os.ModePerm)
+}
+
+// InitDirAndClearFileWithOptions 初始化目录并删除其中相对路径（以/分隔）匹配removePattern的文件，目录不会被删除
+// 与InitDirAndClearFile不同，正则只匹配相对路径，因此"^build/"不会误删其他位置的文件；path可以就是AllowedBase
+func InitDirAndClearFileWithOptions(path string, removePattern string, opts RemoveOptions) ([]string, error) {
+	reg, err := regexp.Compile(removePattern)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClearable(path, opts); err != nil {
+		return nil, err
+	}
+	if !ExistPath(path) {
+		if opts.DryRun {
+			return nil, nil
+		}
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	fileNames, err := Glob(path, "**")
+	if err != nil {
+		return nil, err
+	}
+	r, err := newRemover(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, fileName := range fileNames {
+		rel, err := filepath.Rel(path, fileName)
+		if err != nil {
+			return removed, err
+		}
+		if !reg.MatchString(filepath.ToSlash(rel)) {
+			continue
+		}
+		if err := r.remove(fileName, rel); err != nil {
+			return removed, err
+		}
+		removed = append(removed, fileName)
+	}
+	return removed, nil
+}
+
+// remover 按选项删除或移入回收目录
+type remover struct {
+	opts      RemoveOptions
+	trashRoot string // 本次操作使用的回收子目录
+}
+
+func newRemover(path string, opts RemoveOptions) (*remover, error) {
+	r := &remover{opts: opts}
+	if opts.TrashDir == "" {
+		return r, nil
+	}
+
+	for _, trash := range candidatePaths(opts.TrashDir) {
+		for _, p := range candidatePaths(path) {
+			if trash == p || isWithin(p, trash) {
+				return nil, fmt.Errorf("trash directory %s is inside %s", opts.TrashDir, path)
+			}
+		}
+	}
+	r.trashRoot = filepath.Join(opts.TrashDir, time.Now().Format("20060102-150405.000000000"))
+	return r, nil
+}
+
+// remove 删除path，回收模式下移动到回收子目录中的rel位置
+func (r *remover) remove(path, rel string) error {
+	if r.opts.DryRun {
+		return nil
+	}
+	if r.trashRoot == "" {
+		return os.RemoveAll(path)
+	}
+
+	dst := filepath.Join(r.trashRoot, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(path, dst); err == nil {
+		return nil
+	}
+
+	// 跨设备等无法重命名时，先拷贝再删除
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		_, err = CopyDirWithOptions(path, dst, CopyOptions{Symlinks: SymlinkCopy})
+	} else {
+		err = CopyFile(path, dst)
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
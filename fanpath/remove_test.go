@@ -0,0 +1,126 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckRemovable(t *testing.T) {
+	base := t.TempDir()
+	inside := filepath.Join(base, "build")
+
+	type removeCase struct {
+		path string
+		opts RemoveOptions
+	}
+	unsafe := []removeCase{
+		{"", RemoveOptions{}},
+		{string(filepath.Separator), RemoveOptions{}},
+		{filepath.VolumeName(base) + string(filepath.Separator), RemoveOptions{}},
+		{filepath.Join(base, ".."), RemoveOptions{AllowedBase: base}},
+		{base, RemoveOptions{AllowedBase: base}},
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Dir(home) != home {
+		unsafe = append(unsafe, removeCase{home, RemoveOptions{}}, removeCase{filepath.Dir(home), RemoveOptions{}})
+	}
+	for _, tt := range unsafe {
+		if err := CheckRemovable(tt.path, tt.opts); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("CheckRemovable(%q, %+v) = %v; want ErrUnsafePath", tt.path, tt.opts, err)
+		}
+	}
+
+	if err := CheckRemovable(inside, RemoveOptions{AllowedBase: base}); err != nil {
+		t.Errorf("CheckRemovable(%q) = %v; want nil", inside, err)
+	}
+	if err := ClearDirAndCreateNew(string(filepath.Separator)); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("ClearDirAndCreateNew(root) = %v; want ErrUnsafePath", err)
+	}
+}
+
+func TestClearDirWithTrashAndDryRun(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "out")
+	writeTestFile(t, dir, "a.txt", "a")
+	trash := filepath.Join(base, "trash")
+
+	removed, err := ClearDirAndCreateNewWithOptions(dir, RemoveOptions{AllowedBase: base, DryRun: true})
+	if err != nil || !reflect.DeepEqual(removed, []string{filepath.Join(dir, "a.txt")}) {
+		t.Fatalf("dry run = %v, %v", removed, err)
+	}
+	if !ExistFile(filepath.Join(dir, "a.txt")) {
+		t.Fatal("dry run removed files")
+	}
+
+	if _, err := ClearDirAndCreateNewWithOptions(dir, RemoveOptions{AllowedBase: base, TrashDir: trash}); err != nil {
+		t.Fatalf("ClearDirAndCreateNewWithOptions failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if !ExistDir(dir) || len(entries) != 0 {
+		t.Errorf("directory not recreated empty: %v", entries)
+	}
+	backups, err := Glob(trash, "*/out/a.txt")
+	if err != nil || len(backups) != 1 {
+		t.Errorf("backup not found in trash: %v, %v", backups, err)
+	}
+
+	if _, err := ClearDirAndCreateNewWithOptions(base, RemoveOptions{TrashDir: trash}); err == nil {
+		t.Error("trash inside the removed directory was accepted")
+	}
+}
+
+func TestInitDirAndClearFileRelativePattern(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "build/app.o", "o")
+	writeTestFile(t, dir, "src/build/keep.o", "o")
+	writeTestFile(t, dir, "src/main.c", "c")
+
+	opts := RemoveOptions{DryRun: true}
+	removed, err := InitDirAndClearFileWithOptions(dir, `^build/.*\.o$`, opts)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if want := []string{filepath.Join(dir, "build", "app.o")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("dry run = %v; want %v", removed, want)
+	}
+
+	if _, err := InitDirAndClearFileWithOptions(dir, `^build/.*\.o$`, RemoveOptions{}); err != nil {
+		t.Fatalf("InitDirAndClearFileWithOptions failed: %v", err)
+	}
+	if ExistFile(filepath.Join(dir, "build", "app.o")) || !ExistFile(filepath.Join(dir, "src", "build", "keep.o")) {
+		t.Error("pattern was not applied to relative paths")
+	}
+}
+
+func TestClearAllowedBaseItself(t *testing.T) {
+	base := t.TempDir()
+	writeTestFile(t, base, "a.txt", "a")
+	writeTestFile(t, base, "sub/b.o", "b")
+	opts := RemoveOptions{AllowedBase: base}
+
+	removed, err := InitDirAndClearFileWithOptions(base, `\.o$`, opts)
+	if err != nil {
+		t.Fatalf("InitDirAndClearFileWithOptions(base) failed: %v", err)
+	}
+	if want := []string{filepath.Join(base, "sub", "b.o")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v; want %v", removed, want)
+	}
+
+	removed, err = ClearDirAndCreateNewWithOptions(base, opts)
+	if err != nil {
+		t.Fatalf("ClearDirAndCreateNewWithOptions(base) failed: %v", err)
+	}
+	if want := []string{filepath.Join(base, "a.txt"), filepath.Join(base, "sub")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v; want %v", removed, want)
+	}
+	if entries, _ := os.ReadDir(base); !ExistDir(base) || len(entries) != 0 {
+		t.Errorf("base not left empty: %v", entries)
+	}
+
+	// The base itself still cannot be removed outright.
+	if _, err := SafeRemoveAll(base, opts); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("SafeRemoveAll(base) = %v; want ErrUnsafePath", err)
+	}
+}
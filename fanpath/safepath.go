@@ -0,0 +1,208 @@
+package fanpath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// ErrPathEscapes 路径会指向基准目录之外
+var ErrPathEscapes = errors.New("path escapes base directory")
+
+// maxSymlinkWalk SecureJoin最多跟随的符号链接数量，与linux的MAXSYMLINKS相同
+const maxSymlinkWalk = 40
+
+// IsWithin p是否是base自身或位于base之内，两者都先转为绝对路径并清理，不解析符号链接
+func IsWithin(base, p string) bool {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	return absBase == absPath || isWithin(absBase, absPath)
+}
+
+// SecureJoin 把不可信的相对路径拼接到base下，结果保证位于base之内
+// 路径中已存在的符号链接会被逐级解析，链接目标和".."都被限制在base内：绝对目标以base为根，超出base的".."停在base；
+// 不存在的部分按字面拼接。base自身不做检查，带盘符的untrusted返回ErrPathEscapes
+// 解析结果只在调用时刻成立，base之内的内容仍可能被其他进程修改
+func SecureJoin(base, untrusted string) (string, error) {
+	return secureJoin(base, untrusted, false)
+}
+
+// secureJoin SecureJoin的实现，strict为true时超出base的".."和绝对的链接目标不再被限制在base内，而是返回ErrPathEscapes
+func secureJoin(base, untrusted string, strict bool) (string, error) {
+	if filepath.VolumeName(untrusted) != "" {
+		return "", fmt.Errorf("%w: %s has a volume name", ErrPathEscapes, untrusted)
+	}
+	base = filepath.Clean(base)
+
+	remaining := filepath.ToSlash(untrusted)
+	current := "" // 已解析部分，以/分隔的相对路径
+	links := 0
+	for remaining != "" {
+		var part string
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i+1:]
+		} else {
+			part, remaining = remaining, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if strict && current == "" {
+				return "", fmt.Errorf("%w: %s", ErrPathEscapes, untrusted)
+			}
+			if current = path.Dir(current); current == "." {
+				current = ""
+			}
+			continue
+		}
+
+		next := path.Join(current, part)
+		info, err := os.Lstat(filepath.Join(base, filepath.FromSlash(next)))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+				current = next
+				continue
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		if links++; links > maxSymlinkWalk {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", untrusted)
+		}
+		target, err := os.Readlink(filepath.Join(base, filepath.FromSlash(next)))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+			if strict {
+				return "", fmt.Errorf("%w: %s links to %s", ErrPathEscapes, untrusted, target)
+			}
+			// 绝对目标相对于base解析
+			current = ""
+			target = target[len(filepath.VolumeName(target)):]
+		}
+		remaining = filepath.ToSlash(target) + "/" + remaining
+	}
+	return filepath.Join(base, filepath.FromSlash(current)), nil
+}
+
+// NormalizePath 把路径转为与平台无关的形式，便于比较和存储：
+// 分隔符统一为/并清理，windows盘符转为大写（c:\a -> C:/a），UNC路径保留为//server/share/...，
+// \\?\和\\.\前缀被去掉；任何平台上结果都相同
+func NormalizePath(p string) string {
+	s := strings.ReplaceAll(p, `\`, "/")
+	if strings.HasPrefix(s, "//?/") || strings.HasPrefix(s, "//./") {
+		s = s[4:]
+		if len(s) >= 4 && strings.EqualFold(s[:4], "UNC/") {
+			s = "//" + s[4:]
+		}
+	}
+
+	prefix := ""
+	switch {
+	case len(s) >= 2 && s[1] == ':' && isASCIILetter(s[0]):
+		prefix = strings.ToUpper(s[:1]) + ":"
+		s = s[2:]
+		if s == "" {
+			return prefix
+		}
+	case strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "///"):
+		parts := strings.SplitN(s[2:], "/", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return path.Clean(s)
+		}
+		prefix = "//" + parts[0] + "/" + parts[1]
+		s = "/"
+		if len(parts) == 3 {
+			s += parts[2]
+		}
+	}
+	return prefix + path.Clean(s)
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// windowsReservedNames windows上不能作为文件名（包括带扩展名时）的设备名
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsReservedName name是否是windows保留的设备名，如CON、nul.txt、"LPT1 "，不区分大小写
+func IsReservedName(name string) bool {
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	return windowsReservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// maxFileNameBytes 大多数文件系统对单个文件名的字节数限制
+const maxFileNameBytes = 255
+
+// SanitizeFileName 把用户提供的名称转为在所有平台上都合法的单个文件名：
+// 路径分隔符、windows不允许的字符<>:"|?*和控制字符替换为_，去掉首尾空白和末尾的点，
+// 保留设备名前加_，结果为空或为"."、".."时返回"_"；超过255字节时截断主文件名并保留扩展名
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f || r == utf8.RuneError:
+			return '_'
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+	name = strings.TrimSpace(name)
+	name = strings.TrimRight(name, ". ")
+
+	if name == "" {
+		return "_"
+	}
+	if IsReservedName(name) {
+		name = "_" + name
+	}
+
+	if len(name) > maxFileNameBytes {
+		ext := path.Ext(name)
+		if len(ext) > maxFileNameBytes/2 {
+			ext = ""
+		}
+		stem := name[:len(name)-len(ext)]
+		stem = truncateUTF8(stem, maxFileNameBytes-len(ext))
+		name = strings.TrimRight(stem, ". ") + ext
+	}
+	return name
+}
+
+// truncateUTF8 把s截断到最多n字节，不截断在多字节字符中间
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
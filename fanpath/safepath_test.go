@@ -0,0 +1,122 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIsWithin(t *testing.T) {
+	base := t.TempDir()
+	cases := map[string]bool{
+		base:                          true,
+		filepath.Join(base, "a", "b"): true,
+		filepath.Join(base, "a", "..", "..", "x"): false,
+		filepath.Dir(base):                        false,
+		base + "x":                                false,
+	}
+	for p, want := range cases {
+		if got := IsWithin(base, p); got != want {
+			t.Errorf("IsWithin(%s) = %v; want %v", p, got, want)
+		}
+	}
+}
+
+func TestSecureJoin(t *testing.T) {
+	base := t.TempDir()
+	writeTestFile(t, base, "dir/file.txt", "x")
+
+	cases := map[string]string{
+		"dir/file.txt":          "dir/file.txt",
+		"../../etc/passwd":      "etc/passwd",
+		"dir/../../../missing":  "missing",
+		"/abs/path":             "abs/path",
+		"./dir/./new/../a.txt":  "dir/a.txt",
+		"":                      "",
+		"dir/file.txt/../other": "dir/other",
+	}
+	if runtime.GOOS != "windows" {
+		must := func(err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		must(os.Symlink("../../..", filepath.Join(base, "dir", "up")))
+		must(os.Symlink("/etc", filepath.Join(base, "etclink")))
+		must(os.Symlink("loop", filepath.Join(base, "loop")))
+		cases["dir/up/escape"] = "escape"
+		cases["etclink/passwd"] = "etc/passwd"
+	}
+
+	for untrusted, want := range cases {
+		got, err := SecureJoin(base, untrusted)
+		if err != nil {
+			t.Errorf("SecureJoin(%q) failed: %v", untrusted, err)
+			continue
+		}
+		if want := filepath.Join(base, filepath.FromSlash(want)); got != want {
+			t.Errorf("SecureJoin(%q) = %s; want %s", untrusted, got, want)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		if _, err := SecureJoin(base, "loop/x"); err == nil {
+			t.Error("SecureJoin through a symlink loop should fail")
+		}
+	}
+	if runtime.GOOS == "windows" {
+		if _, err := SecureJoin(base, `C:\Windows`); !errors.Is(err, ErrPathEscapes) {
+			t.Errorf("SecureJoin with a volume = %v; want ErrPathEscapes", err)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		`a\b\..\c`:                    "a/c",
+		"a//b/./c/":                   "a/b/c",
+		`c:\Users\x\..\y`:             "C:/Users/y",
+		"d:":                          "D:",
+		"d:rel\\x":                    "D:rel/x",
+		`\\server\share\dir\..\f.txt`: "//server/share/f.txt",
+		`\\server\share`:              "//server/share/",
+		`\\?\C:\long\path`:            "C:/long/path",
+		`\\?\UNC\server\share\x`:      "//server/share/x",
+		"/usr/../etc":                 "/etc",
+		"":                            ".",
+	}
+	for in, want := range cases {
+		if got := NormalizePath(in); got != want {
+			t.Errorf("NormalizePath(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	cases := map[string]string{
+		"report.pdf":           "report.pdf",
+		"a/b\\c:d*e?f\"g<h>i|": "a_b_c_d_e_f_g_h_i_",
+		"  name. . ":           "name",
+		"..":                   "_",
+		"":                     "_",
+		"CON":                  "_CON",
+		"nul.txt":              "_nul.txt",
+		"console":              "console",
+		"tab\there\x00":        "tab_here_",
+		"中文名.txt":              "中文名.txt",
+	}
+	for in, want := range cases {
+		if got := SanitizeFileName(in); got != want {
+			t.Errorf("SanitizeFileName(%q) = %q; want %q", in, got, want)
+		}
+	}
+
+	long := SanitizeFileName(strings.Repeat("文", 100) + ".txt")
+	if len(long) > 255 || !strings.HasSuffix(long, ".txt") || !utf8.ValidString(long) {
+		t.Errorf("long name sanitized to %q (%d bytes)", long, len(long))
+	}
+}
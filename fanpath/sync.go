@@ -0,0 +1,176 @@
+package fanpath
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// CompareMode 同步目录时判断文件是否变化的方式
+type CompareMode int
+
+const (
+	CompareSizeAndModTime CompareMode = iota // 大小或修改时间不同即视为变化（默认）
+	CompareHash                              // 大小不同或内容哈希不同才视为变化
+)
+
+// SyncOptions 同步目录的选项
+type SyncOptions struct {
+	Compare       CompareMode      // 判断文件是否变化的方式
+	Exclude       []string         // glob规则，规则同CopyOptions.Exclude；匹配的路径既不拷贝，也不会从目标目录删除
+	ExcludeRegexp []*regexp.Regexp // 正则规则，匹配相对路径
+	Delete        bool             // 删除目标目录中源目录不存在的文件和目录
+	DryRun        bool             // 只生成报告，不修改目标目录
+	Symlinks      SymlinkMode      // 符号链接的处理方式
+	Workers       int              // 并行拷贝文件的worker数量
+}
+
+// SyncReport 同步结果，路径均为以/分隔的相对路径
+type SyncReport struct {
+	Added   []string      // 新拷贝的文件
+	Updated []string      // 有变化而被覆盖的文件
+	Deleted []string      // 从目标目录删除的文件和目录，删除的目录不再列出其中的内容
+	Skipped []string      // 没有变化或被排除的文件
+	Failed  []CopyFailure // 失败的文件和目录
+	Bytes   int64         // 拷贝的总字节数
+}
+
+// SyncDir 把dst同步成与src一致：拷贝新增和有变化的文件，opts.Delete为true时删除dst中多余的文件
+// 删除在拷贝之前进行，因此源目录中文件和目录互相替换的情况也能正确同步
+// dst与src相同或是src的上级目录时返回包装了ErrUnsafePath的错误；opts.Delete为true时dst还必须通过CheckRemovable检查
+func SyncDir(src, dst string, opts SyncOptions) (*SyncReport, error) {
+	copyOpts := CopyOptions{
+		Symlinks:      opts.Symlinks,
+		Exclude:       opts.Exclude,
+		ExcludeRegexp: opts.ExcludeRegexp,
+		Overwrite:     OverwriteIfChanged,
+		DryRun:        opts.DryRun,
+		Workers:       opts.Workers,
+	}
+	if opts.Compare == CompareHash {
+		copyOpts.Overwrite = OverwriteIfDifferent
+	}
+
+	filter, err := newCopyFilter(copyOpts)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(src); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", src)
+	}
+
+	if err := checkSyncPaths(src, dst, opts); err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{}
+	if opts.Delete {
+		deleteExtraneous(src, dst, "", filter, opts, report)
+	}
+
+	summary, err := CopyDirWithOptions(src, dst, copyOpts)
+	if summary == nil {
+		return report, err
+	}
+
+	replaced := make(map[string]bool, len(summary.Replaced))
+	for _, rel := range summary.Replaced {
+		replaced[rel] = true
+	}
+	for _, rel := range summary.Copied {
+		if replaced[rel] {
+			report.Updated = append(report.Updated, rel)
+		} else {
+			report.Added = append(report.Added, rel)
+		}
+	}
+	report.Skipped = summary.Skipped
+	report.Failed = append(report.Failed, summary.Failed...)
+	report.Bytes = summary.Bytes
+
+	var errs []error
+	for _, failure := range report.Failed {
+		errs = append(errs, fmt.Errorf("%s: %w", failure.Path, failure.Err))
+	}
+	return report, errors.Join(errs...)
+}
+
+// checkSyncPaths 在修改dst之前检查路径：dst不能是src自身或src的上级目录，否则删除多余文件时会删掉源目录
+func checkSyncPaths(src, dst string, opts SyncOptions) error {
+	for _, s := range candidatePaths(src) {
+		for _, d := range candidatePaths(dst) {
+			if s == d || isWithin(d, s) {
+				return fmt.Errorf("%w: %s contains the source directory %s", ErrUnsafePath, dst, src)
+			}
+		}
+	}
+	if opts.Delete {
+		return CheckRemovable(dst, RemoveOptions{})
+	}
+	return nil
+}
+
+// deleteExtraneous 删除dst中src没有的路径，以及在src中类型不同（文件与目录）的路径
+func deleteExtraneous(src, dst, rel string, filter *copyFilter, opts SyncOptions, report *SyncReport) {
+	entries, err := os.ReadDir(filepath.Join(dst, filepath.FromSlash(rel)))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			report.Failed = append(report.Failed, CopyFailure{Path: rel, Err: err})
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		dstIsDir := entry.IsDir()
+		if filter.excluded(entryRel, dstIsDir) {
+			continue
+		}
+
+		srcExists, srcIsDir := syncSourceType(filepath.Join(src, filepath.FromSlash(entryRel)), opts.Symlinks)
+		if srcExists && srcIsDir == dstIsDir {
+			if dstIsDir {
+				deleteExtraneous(src, dst, entryRel, filter, opts, report)
+			}
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := os.RemoveAll(filepath.Join(dst, filepath.FromSlash(entryRel))); err != nil {
+				report.Failed = append(report.Failed, CopyFailure{Path: entryRel, Err: err})
+				continue
+			}
+		}
+		report.Deleted = append(report.Deleted, entryRel)
+	}
+}
+
+// syncSourceType 返回源路径是否存在以及是否为目录，符号链接按选项处理
+func syncSourceType(srcPath string, mode SymlinkMode) (exists, isDir bool) {
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		return false, false
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return true, info.IsDir()
+	}
+
+	switch mode {
+	case SymlinkSkip:
+		return false, false
+	case SymlinkCopy:
+		return true, false
+	default:
+		info, err = os.Stat(srcPath)
+		if err != nil {
+			return false, false
+		}
+		return true, info.IsDir()
+	}
+}
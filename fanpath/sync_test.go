@@ -0,0 +1,137 @@
+package fanpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func sortedReport(r *SyncReport) *SyncReport {
+	for _, list := range [][]string{r.Added, r.Updated, r.Deleted, r.Skipped} {
+		sort.Strings(list)
+	}
+	return r
+}
+
+func TestSyncDir(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, src, "keep.txt", "keep")
+	writeTestFile(t, src, "change.txt", "v1")
+	writeTestFile(t, src, "sub/nested.txt", "nested")
+	writeTestFile(t, dst, "extra.txt", "extra")
+	writeTestFile(t, dst, "olddir/file.txt", "old")
+	writeTestFile(t, dst, "cache/keep.bin", "excluded")
+
+	opts := SyncOptions{Delete: true, Exclude: []string{"cache"}}
+	report, err := SyncDir(src, dst, opts)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	sortedReport(report)
+	if want := []string{"change.txt", "keep.txt", "sub/nested.txt"}; !reflect.DeepEqual(report.Added, want) {
+		t.Errorf("Added = %v; want %v", report.Added, want)
+	}
+	if want := []string{"extra.txt", "olddir"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("Deleted = %v; want %v", report.Deleted, want)
+	}
+	if !ExistFile(filepath.Join(dst, "cache", "keep.bin")) {
+		t.Error("excluded file was deleted")
+	}
+
+	// A second run is a no-op; a changed file is updated.
+	later := time.Now().Add(time.Minute)
+	changed := writeTestFile(t, src, "change.txt", "v2")
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	report, err = SyncDir(src, dst, opts)
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	sortedReport(report)
+	if len(report.Added) != 0 || len(report.Deleted) != 0 || !reflect.DeepEqual(report.Updated, []string{"change.txt"}) {
+		t.Errorf("second sync report = %+v", report)
+	}
+	if got := readTestFile(t, dst, "change.txt"); got != "v2" {
+		t.Errorf("change.txt = %q; want \"v2\"", got)
+	}
+}
+
+func TestSyncDirTypeChangeAndDryRun(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, src, "entry/file.txt", "now a dir")
+	writeTestFile(t, dst, "entry", "was a file")
+	writeTestFile(t, dst, "stale.txt", "stale")
+
+	report, err := SyncDir(src, dst, SyncOptions{Delete: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	sortedReport(report)
+	if want := []string{"entry", "stale.txt"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("dry run Deleted = %v; want %v", report.Deleted, want)
+	}
+	if !ExistFile(filepath.Join(dst, "stale.txt")) || !ExistFile(filepath.Join(dst, "entry")) {
+		t.Fatal("dry run modified the destination")
+	}
+
+	if _, err := SyncDir(src, dst, SyncOptions{Delete: true}); err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if got := readTestFile(t, dst, "entry/file.txt"); got != "now a dir" {
+		t.Errorf("entry/file.txt = %q", got)
+	}
+}
+
+func TestSyncDirCompareHash(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, src, "same.txt", "abc")
+	writeTestFile(t, dst, "same.txt", "abc")
+	writeTestFile(t, src, "diff.txt", "abc")
+	writeTestFile(t, dst, "diff.txt", "xyz")
+
+	report, err := SyncDir(src, dst, SyncOptions{Compare: CompareHash})
+	if err != nil {
+		t.Fatalf("SyncDir failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Updated, []string{"diff.txt"}) || !reflect.DeepEqual(report.Skipped, []string{"same.txt"}) {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestSyncDirRejectsSourceInsideTarget(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "sub")
+	writeTestFile(t, src, "data.txt", "data")
+	writeTestFile(t, root, "other.txt", "other")
+
+	for _, dst := range []string{root, src} {
+		if _, err := SyncDir(src, dst, SyncOptions{Delete: true}); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("SyncDir(%s, %s) error = %v; want ErrUnsafePath", src, dst, err)
+		}
+	}
+	if !ExistFile(filepath.Join(src, "data.txt")) || !ExistFile(filepath.Join(root, "other.txt")) {
+		t.Error("rejected SyncDir modified the tree")
+	}
+}
+
+func TestSyncDirDeleteChecksRemovable(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src, "data.txt", "data")
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	writeTestFile(t, home, "precious.txt", "keep")
+
+	if _, err := SyncDir(src, home, SyncOptions{Delete: true}); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("SyncDir into home with Delete error = %v; want ErrUnsafePath", err)
+	}
+	if !ExistFile(filepath.Join(home, "precious.txt")) {
+		t.Error("SyncDir deleted files in the home directory")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package fanpath
+
+import "os"
+
+// FsyncDir 对目录调用fsync，使目录项的变化（创建、重命名、删除）落盘
+func FsyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package fanpath
+
+// FsyncDir Windows不支持对目录调用FlushFileBuffers，NTFS的元数据由日志保证，这里不做处理
+func FsyncDir(dir string) error {
+	return nil
+}
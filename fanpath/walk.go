@@ -0,0 +1,220 @@
+package fanpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WalkOptions 遍历目录的选项
+type WalkOptions struct {
+	MaxDepth       int  // 最大深度，root的直接子项深度为1，0表示不限制
+	FollowSymlinks bool // 跟随指向目录的符号链接，成环的链接不再进入，并以错误的形式报告
+	Concurrency    int  // 同时读取目录的goroutine数量；小于等于1且不跟随符号链接时按filepath.WalkDir的顺序遍历，否则顺序不确定
+	Buffer         int  // 结果channel的缓冲大小
+}
+
+// WalkEntry 遍历得到的一项
+type WalkEntry struct {
+	Path  string      // 包含root前缀的路径
+	Depth int         // 深度，root为0
+	Entry fs.DirEntry // 目录项，跟随符号链接时为链接目标的信息；出错时可能为nil
+	Err   error       // 读取该路径或该目录的内容出错
+}
+
+// ErrSymlinkLoop 跟随符号链接时遇到成环的链接
+var ErrSymlinkLoop = errors.New("symlink loop")
+
+// Walk 流式遍历root，返回的channel在遍历结束或ctx取消后关闭
+// root自身作为第一项返回；目录总是先于其中的内容返回；出错的目录以Err非nil的项返回，遍历会继续
+// 调用方需要读完channel或者取消ctx，否则遍历的goroutine会一直阻塞
+func Walk(ctx context.Context, root string, opts WalkOptions) <-chan WalkEntry {
+	out := make(chan WalkEntry, opts.Buffer)
+	w := &walker{ctx: ctx, root: root, opts: opts, out: out}
+
+	go func() {
+		defer close(out)
+		if opts.Concurrency <= 1 && !opts.FollowSymlinks {
+			w.walkDir()
+			return
+		}
+		w.walkConcurrent()
+	}()
+	return out
+}
+
+// walker 一次遍历的状态
+type walker struct {
+	ctx  context.Context
+	root string
+	opts WalkOptions
+	out  chan<- WalkEntry
+}
+
+// send 发送一项结果，ctx取消时返回false
+func (w *walker) send(e WalkEntry) bool {
+	select {
+	case w.out <- e:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// depth 路径相对root的深度
+func (w *walker) depth(path string) int {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// walkDir 单goroutine、不跟随符号链接时直接使用filepath.WalkDir
+func (w *walker) walkDir() {
+	filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if w.ctx.Err() != nil {
+			return w.ctx.Err()
+		}
+		depth := w.depth(path)
+		if !w.send(WalkEntry{Path: path, Depth: depth, Entry: d, Err: err}) {
+			return w.ctx.Err()
+		}
+		if d != nil && d.IsDir() && w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// dirChain 从root到当前目录的真实路径链，用于检测符号链接成环，各分支共享父节点
+type dirChain struct {
+	real   string
+	parent *dirChain
+}
+
+func (c *dirChain) contains(real string) bool {
+	for ; c != nil; c = c.parent {
+		if c.real == real {
+			return true
+		}
+	}
+	return false
+}
+
+// walkQueuePerWorker 并发遍历时共享队列中为每个worker预留的目录数
+const walkQueuePerWorker = 64
+
+// dirJob 等待读取的目录
+type dirJob struct {
+	dir   string
+	depth int
+	chain *dirChain
+}
+
+// walkConcurrent 由固定的opts.Concurrency个worker从有界队列中取目录读取
+// 队列满时worker把发现的子目录留在自己的栈中处理，因此不会因为队列满而互相等待
+func (w *walker) walkConcurrent() {
+	info, err := os.Lstat(w.root)
+	if err != nil {
+		w.send(WalkEntry{Path: w.root, Err: err})
+		return
+	}
+	if info.Mode()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks {
+		if target, err := os.Stat(w.root); err == nil {
+			info = target
+		}
+	}
+	entry := fs.FileInfoToDirEntry(info)
+	if !w.send(WalkEntry{Path: w.root, Entry: entry}) || !entry.IsDir() {
+		return
+	}
+
+	concurrency := w.opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	queue := make(chan dirJob, concurrency*walkQueuePerWorker)
+	// pending 已发现但还没读完的目录数，归零后关闭队列让worker退出
+	var pending sync.WaitGroup
+	pending.Add(1)
+	queue <- dirJob{dir: w.root}
+	go func() {
+		pending.Wait()
+		close(queue)
+	}()
+
+	var workers sync.WaitGroup
+	workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer workers.Done()
+			for job := range queue {
+				stack := []dirJob{job}
+				for len(stack) > 0 {
+					job := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					for _, sub := range w.readDir(job) {
+						pending.Add(1)
+						select {
+						case queue <- sub:
+						default:
+							stack = append(stack, sub)
+						}
+					}
+					pending.Done()
+				}
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// readDir 读取job中的目录并发送其中的条目，返回需要继续读取的子目录；ctx取消后直接返回nil
+func (w *walker) readDir(job dirJob) []dirJob {
+	if w.ctx.Err() != nil {
+		return nil
+	}
+	chain := job.chain
+	if w.opts.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(job.dir)
+		if err != nil {
+			w.send(WalkEntry{Path: job.dir, Depth: job.depth, Err: err})
+			return nil
+		}
+		if chain.contains(real) {
+			w.send(WalkEntry{Path: job.dir, Depth: job.depth, Err: fmt.Errorf("%w at %s", ErrSymlinkLoop, job.dir)})
+			return nil
+		}
+		chain = &dirChain{real: real, parent: chain}
+	}
+
+	entries, err := os.ReadDir(job.dir)
+	if err != nil {
+		if !w.send(WalkEntry{Path: job.dir, Depth: job.depth, Err: err}) {
+			return nil
+		}
+	}
+
+	var subdirs []dirJob
+	for _, entry := range entries {
+		path := filepath.Join(job.dir, entry.Name())
+		if entry.Type()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks {
+			if info, err := os.Stat(path); err == nil {
+				entry = fs.FileInfoToDirEntry(info)
+			}
+		}
+		if !w.send(WalkEntry{Path: path, Depth: job.depth + 1, Entry: entry}) {
+			return nil
+		}
+		if entry.IsDir() && (w.opts.MaxDepth <= 0 || job.depth+1 < w.opts.MaxDepth) {
+			subdirs = append(subdirs, dirJob{dir: path, depth: job.depth + 1, chain: chain})
+		}
+	}
+	return subdirs
+}
@@ -0,0 +1,130 @@
+package fanpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collectWalk drains the walker and returns relative paths of all entries
+// plus the errors reported on the way.
+func collectWalk(t *testing.T, root string, opts WalkOptions) ([]string, []error) {
+	t.Helper()
+	var rels []string
+	var errs []error
+	for e := range Walk(context.Background(), root, opts) {
+		if e.Err != nil {
+			errs = append(errs, e.Err)
+			continue
+		}
+		rel, err := filepath.Rel(root, e.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	sort.Strings(rels)
+	return rels, errs
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "a.txt", "a")
+	writeTestFile(t, root, "sub/b.txt", "b")
+	writeTestFile(t, root, "sub/deep/c.txt", "c")
+
+	all := []string{".", "a.txt", "sub", "sub/b.txt", "sub/deep", "sub/deep/c.txt"}
+	for _, concurrency := range []int{0, 4} {
+		got, errs := collectWalk(t, root, WalkOptions{Concurrency: concurrency})
+		if len(errs) != 0 || !reflect.DeepEqual(got, all) {
+			t.Errorf("concurrency %d: got %v, errors %v; want %v", concurrency, got, errs, all)
+		}
+
+		got, _ = collectWalk(t, root, WalkOptions{Concurrency: concurrency, MaxDepth: 1})
+		if want := []string{".", "a.txt", "sub"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d, depth 1: got %v; want %v", concurrency, got, want)
+		}
+	}
+}
+
+func TestWalkFollowSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+
+	root := t.TempDir()
+	writeTestFile(t, root, "data/file.txt", "content")
+	if err := os.Symlink("data", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(root, "data", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, errs := collectWalk(t, root, WalkOptions{FollowSymlinks: true})
+	want := []string{".", "data", "data/file.txt", "data/loop", "link", "link/file.txt", "link/loop"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors %v; want one loop per link", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrSymlinkLoop) {
+			t.Errorf("error %v is not ErrSymlinkLoop", err)
+		}
+	}
+}
+
+func TestWalkCancel(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		writeTestFile(t, root, filepath.Join("d", string(rune('a'+i)), "f.txt"), "x")
+	}
+
+	for _, concurrency := range []int{0, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		entries := Walk(ctx, root, WalkOptions{Concurrency: concurrency})
+		<-entries
+		cancel()
+		// The channel must be closed soon after cancellation even though
+		// nobody reads the remaining entries.
+		for range entries {
+		}
+	}
+}
+
+func TestWalkConcurrentBoundedGoroutines(t *testing.T) {
+	root := t.TempDir()
+	const dirs = 300
+	for i := 0; i < dirs; i++ {
+		writeTestFile(t, root, filepath.Join("d", fmt.Sprint(i), "sub", "f.txt"), "x")
+	}
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	entries := Walk(ctx, root, WalkOptions{Concurrency: 2})
+	count := 0
+	for range entries {
+		count++
+		if count == dirs+2 {
+			// Stall the reader: the walker must stay within its fixed worker
+			// pool instead of starting a goroutine per pending directory.
+			time.Sleep(50 * time.Millisecond)
+			if n := runtime.NumGoroutine() - before; n > 2+3 {
+				t.Errorf("walker is running %d extra goroutines; want at most 5", n)
+			}
+		}
+	}
+	if want := 2 + dirs*3; count != want {
+		t.Errorf("got %d entries; want %d", count, want)
+	}
+}
@@ -0,0 +1,331 @@
+package fanpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WatchOp 文件变化的类型
+type WatchOp int
+
+const (
+	OpCreate WatchOp = iota + 1 // 创建，包括从其他位置移入
+	OpModify                    // 内容变化
+	OpRemove                    // 删除
+	OpRename                    // 移走或重命名，只有原生监视能区分，轮询时报告为OpRemove
+)
+
+func (op WatchOp) String() string {
+	switch op {
+	case OpCreate:
+		return "create"
+	case OpModify:
+		return "modify"
+	case OpRemove:
+		return "remove"
+	case OpRename:
+		return "rename"
+	default:
+		return fmt.Sprintf("WatchOp(%d)", int(op))
+	}
+}
+
+const (
+	DefaultWatchDebounce     = 100 * time.Millisecond // 默认的防抖时间
+	DefaultWatchPollInterval = time.Second            // 默认的轮询间隔
+)
+
+// ErrWatchOverflow 内核事件队列溢出，部分事件已经丢失，调用方应当重新扫描目录
+var ErrWatchOverflow = errors.New("watch event queue overflow")
+
+// WatchOptions 监视目录的选项
+type WatchOptions struct {
+	Patterns        []string      // 过滤规则，语法同Glob；排除的目录不再监视，为空时监视所有文件
+	CaseInsensitive bool          // 匹配规则时忽略大小写
+	IncludeDirs     bool          // 同时报告目录的创建和删除
+	Debounce        time.Duration // 防抖时间，路径在这段时间内没有新的变化后才报告，同一路径的多次变化会合并
+	MaxDelay        time.Duration // 从第一个未报告的变化开始，最多等待这么久就报告，避免持续的变化一直推迟报告；小于等于0时为Debounce的10倍
+	PollInterval    time.Duration // 轮询间隔，只在轮询模式下使用
+	Poll            bool          // 强制使用轮询，否则只在不支持原生监视的平台上使用轮询
+}
+
+// WatchEvent 文件变化事件
+type WatchEvent struct {
+	Path string  // 包含root前缀的路径
+	Op   WatchOp // 变化类型，Err非nil时为0
+	Err  error   // 监视出错，如ErrWatchOverflow
+}
+
+// Watch 递归监视root下的文件变化，返回的channel在ctx取消后关闭
+// linux上使用inotify，其他平台或opts.Poll为true时定期比较文件的大小和修改时间
+// 事件经过防抖与合并：创建后修改报告为创建，创建后删除不报告，删除后重新创建报告为修改
+func Watch(ctx context.Context, root string, opts WatchOptions) (<-chan WatchEvent, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * opts.Debounce
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWatchPollInterval
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	g, err := newGlobber(GlobOptions{CaseInsensitive: opts.CaseInsensitive}, opts.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	filter := &watchFilter{globber: g, includeDirs: opts.IncludeDirs}
+
+	raw := make(chan WatchEvent, 64)
+	native := false
+	if !opts.Poll {
+		native = startNativeWatch(ctx, root, filter, raw) == nil
+	}
+	if !native {
+		prev, err := filter.snapshot(root)
+		if err != nil {
+			return nil, err
+		}
+		go pollWatch(ctx, root, filter, opts.PollInterval, prev, raw)
+	}
+
+	out := make(chan WatchEvent)
+	go debounceWatch(ctx, raw, out, opts.Debounce, opts.MaxDelay)
+	return out, nil
+}
+
+// watchFilter 按Glob规则决定监视哪些目录、报告哪些路径
+type watchFilter struct {
+	*globber
+	includeDirs bool
+}
+
+// excluded 路径是否被排除，被排除的目录不再监视
+func (f *watchFilter) excluded(rel string, isDir bool) bool {
+	return matchAny(f.excludes, rel, isDir)
+}
+
+// reported 是否报告该路径的事件
+func (f *watchFilter) reported(rel string, isDir bool) bool {
+	if f.excluded(rel, isDir) || (isDir && !f.includeDirs) {
+		return false
+	}
+	return matchAny(f.includes, rel, isDir)
+}
+
+// fileState 轮询时记录的文件状态
+type fileState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// snapshot 记录root下所有需要报告的路径的大小和修改时间
+func (f *watchFilter) snapshot(root string) (map[string]fileState, error) {
+	states := make(map[string]fileState)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历期间被删除的路径留到下一轮处理
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if f.excluded(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.reported(rel, d.IsDir()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		states[rel] = fileState{size: info.Size(), modTime: info.ModTime(), isDir: d.IsDir()}
+		return nil
+	})
+	return states, err
+}
+
+// pollWatch 定期比较快照，把差异作为事件发送到raw，ctx取消后关闭raw
+func pollWatch(ctx context.Context, root string, filter *watchFilter, interval time.Duration, prev map[string]fileState, raw chan<- WatchEvent) {
+	defer close(raw)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	send := func(e WatchEvent) bool {
+		select {
+		case raw <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cur, err := filter.snapshot(root)
+		if err != nil {
+			if !send(WatchEvent{Path: root, Err: err}) {
+				return
+			}
+			continue
+		}
+
+		for _, e := range diffSnapshots(root, prev, cur) {
+			if !send(e) {
+				return
+			}
+		}
+		prev = cur
+	}
+}
+
+// diffSnapshots 比较两次快照，目录只报告创建和删除
+func diffSnapshots(root string, prev, cur map[string]fileState) []WatchEvent {
+	var events []WatchEvent
+	for rel, state := range cur {
+		old, ok := prev[rel]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent{Path: filepath.Join(root, filepath.FromSlash(rel)), Op: OpCreate})
+		case !state.isDir && (old.size != state.size || !old.modTime.Equal(state.modTime)):
+			events = append(events, WatchEvent{Path: filepath.Join(root, filepath.FromSlash(rel)), Op: OpModify})
+		}
+	}
+	for rel := range prev {
+		if _, ok := cur[rel]; !ok {
+			events = append(events, WatchEvent{Path: filepath.Join(root, filepath.FromSlash(rel)), Op: OpRemove})
+		}
+	}
+	return events
+}
+
+// mergeWatchOp 合并同一路径上先后发生的两次变化，keep为false表示两次变化相互抵消
+func mergeWatchOp(old, cur WatchOp) (op WatchOp, keep bool) {
+	switch {
+	case old == OpCreate && (cur == OpRemove || cur == OpRename):
+		return 0, false
+	case old == OpCreate:
+		return OpCreate, true
+	case (old == OpRemove || old == OpRename) && cur == OpCreate:
+		return OpModify, true
+	default:
+		return cur, true
+	}
+}
+
+// debounceWatch 合并raw中的事件，在debounce时间内没有新事件或者第一个未发送的事件已等待maxDelay后按路径顺序发送到out
+// 错误事件不经过合并，立即发送；raw关闭时先发送未发送的事件再关闭out
+func debounceWatch(ctx context.Context, raw <-chan WatchEvent, out chan<- WatchEvent, debounce, maxDelay time.Duration) {
+	defer close(out)
+
+	pending := make(map[string]WatchOp)
+	var deadline time.Time // 第一个未发送的事件最晚的发送时间，没有未发送的事件时为零值
+	timer := time.NewTimer(debounce)
+	stopTimer(timer)
+
+	send := func(e WatchEvent) bool {
+		select {
+		case out <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	flush := func() bool {
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if !send(WatchEvent{Path: p, Op: pending[p]}) {
+				return false
+			}
+		}
+		pending = make(map[string]WatchOp)
+		deadline = time.Time{}
+		return true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-raw:
+			if !ok {
+				flush()
+				return
+			}
+			if e.Err != nil {
+				if !send(e) {
+					return
+				}
+				continue
+			}
+			if old, ok := pending[e.Path]; ok {
+				op, keep := mergeWatchOp(old, e.Op)
+				if keep {
+					pending[e.Path] = op
+				} else {
+					delete(pending, e.Path)
+				}
+			} else {
+				pending[e.Path] = e.Op
+			}
+
+			now := time.Now()
+			if deadline.IsZero() {
+				deadline = now.Add(maxDelay)
+			}
+			wait := debounce
+			if remaining := deadline.Sub(now); remaining < wait {
+				wait = remaining
+			}
+			stopTimer(timer)
+			timer.Reset(wait)
+		case <-timer.C:
+			if !flush() {
+				return
+			}
+		}
+	}
+}
+
+// stopTimer 停止timer并清空已经到期但还没有读取的值，之后可以安全地Reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
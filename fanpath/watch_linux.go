@@ -0,0 +1,216 @@
+package fanpath
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask 监视的inotify事件
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyWatcher 基于inotify的递归监视，每个目录一个watch
+type inotifyWatcher struct {
+	ctx    context.Context
+	root   string
+	filter *watchFilter
+	raw    chan<- WatchEvent
+	file   *os.File
+	wds    map[int32]string // watch描述符到目录相对路径的映射，root为""
+}
+
+// startNativeWatch 启动inotify监视，失败时由调用方退回轮询
+func startNativeWatch(ctx context.Context, root string, filter *watchFilter, raw chan<- WatchEvent) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	// 非阻塞的fd交给runtime的poller，Close可以打断阻塞中的Read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	w := &inotifyWatcher{ctx: ctx, root: root, filter: filter, raw: raw, file: file, wds: make(map[int32]string)}
+	if err := w.addTree("", false); err != nil {
+		file.Close()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	go w.run()
+	return nil
+}
+
+// run 读取并处理inotify事件，fd关闭后关闭raw
+func (w *inotifyWatcher) run() {
+	defer close(w.raw)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if w.ctx.Err() == nil {
+				w.send(WatchEvent{Path: w.root, Err: err})
+			}
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			off = nameStart + int(event.Len)
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+// send 发送事件，ctx取消时丢弃
+func (w *inotifyWatcher) send(e WatchEvent) {
+	select {
+	case w.raw <- e:
+	case <-w.ctx.Done():
+	}
+}
+
+// emit 按过滤规则发送rel的事件
+func (w *inotifyWatcher) emit(rel string, isDir bool, op WatchOp) {
+	if w.filter.reported(rel, isDir) {
+		w.send(WatchEvent{Path: filepath.Join(w.root, filepath.FromSlash(rel)), Op: op})
+	}
+}
+
+// handle 处理一个inotify事件
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.send(WatchEvent{Path: w.root, Err: ErrWatchOverflow})
+		return
+	}
+	dir, ok := w.wds[wd]
+	if !ok {
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.wds, wd)
+		return
+	}
+	if name == "" {
+		return
+	}
+
+	rel := path.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+	if w.filter.excluded(rel, isDir) {
+		return
+	}
+
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		w.emit(rel, isDir, OpCreate)
+		if isDir {
+			// 新目录中在添加watch之前创建的内容由扫描补报
+			if err := w.addTree(rel, true); err != nil {
+				w.send(WatchEvent{Path: filepath.Join(w.root, filepath.FromSlash(rel)), Err: err})
+			}
+		}
+	case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+		if !isDir {
+			w.emit(rel, false, OpModify)
+		}
+	case mask&syscall.IN_DELETE != 0:
+		w.emit(rel, isDir, OpRemove)
+	case mask&syscall.IN_MOVED_FROM != 0:
+		w.emit(rel, isDir, OpRename)
+		if isDir {
+			w.removeTree(rel)
+		}
+	}
+}
+
+// addTree 为rel及其所有未被排除的子目录添加watch，emitCreates为true时为其中已有的内容报告创建事件
+func (w *inotifyWatcher) addTree(rel string, emitCreates bool) error {
+	start := filepath.Join(w.root, filepath.FromSlash(rel))
+	return filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 添加watch期间被删除的目录会由后续的删除事件报告
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		sub, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+		sub = filepath.ToSlash(sub)
+		if sub == "." {
+			sub = ""
+		}
+
+		if p != start {
+			if w.filter.excluded(sub, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if emitCreates {
+				w.emit(sub, d.IsDir(), OpCreate)
+			}
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		wd, err := w.addWatch(p)
+		if err != nil {
+			if err == syscall.ENOENT || err == syscall.ENOTDIR {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		w.wds[int32(wd)] = sub
+		return nil
+	})
+}
+
+// addWatch 通过RawConn调用inotify_add_watch，避免与ctx取消时的Close竞争
+func (w *inotifyWatcher) addWatch(dir string) (int, error) {
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var wd int
+	var addErr error
+	err = conn.Control(func(fd uintptr) {
+		wd, addErr = syscall.InotifyAddWatch(int(fd), dir, inotifyMask)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wd, addErr
+}
+
+// removeTree 移除rel及其子目录的watch，用于目录被移走的情况
+func (w *inotifyWatcher) removeTree(rel string) {
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return
+	}
+	for wd, dir := range w.wds {
+		if dir == rel || strings.HasPrefix(dir, rel+"/") {
+			wd := wd
+			conn.Control(func(fd uintptr) {
+				syscall.InotifyRmWatch(int(fd), uint32(wd))
+			})
+			delete(w.wds, wd)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fanpath
+
+import (
+	"context"
+	"errors"
+)
+
+// startNativeWatch 非linux平台没有原生监视，总是使用轮询
+func startNativeWatch(ctx context.Context, root string, filter *watchFilter, raw chan<- WatchEvent) error {
+	return errors.New("native watch is not supported on this platform")
+}
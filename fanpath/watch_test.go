@@ -0,0 +1,192 @@
+package fanpath
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// watchModes returns the backends available on this platform.
+func watchModes() map[string]bool {
+	modes := map[string]bool{"poll": true}
+	if runtime.GOOS == "linux" {
+		modes["native"] = false
+	}
+	return modes
+}
+
+func startTestWatch(t *testing.T, root string, opts WatchOptions) <-chan WatchEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	opts.Debounce = 50 * time.Millisecond
+	opts.PollInterval = 20 * time.Millisecond
+	events, err := Watch(ctx, root, opts)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	return events
+}
+
+// expectWatchEvents reads events until all wanted (relative path, op) pairs
+// have been seen, failing on anything unexpected.
+func expectWatchEvents(t *testing.T, root string, events <-chan WatchEvent, want map[string]WatchOp) {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for len(want) > 0 {
+		select {
+		case e := <-events:
+			if e.Err != nil {
+				t.Fatalf("watch error: %v", e.Err)
+			}
+			rel, _ := filepath.Rel(root, e.Path)
+			rel = filepath.ToSlash(rel)
+			if op, ok := want[rel]; !ok || op != e.Op {
+				t.Fatalf("unexpected event %s %s; still waiting for %v", e.Op, rel, want)
+			}
+			delete(want, rel)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+}
+
+func expectNoWatchEvent(t *testing.T, events <-chan WatchEvent, wait time.Duration) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %s %s (err %v)", e.Op, e.Path, e.Err)
+	case <-time.After(wait):
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for name, poll := range watchModes() {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFile(t, root, "existing.txt", "old")
+			events := startTestWatch(t, root, WatchOptions{Poll: poll, Patterns: []string{"!ignored"}})
+
+			writeTestFile(t, root, "new.txt", "hello")
+			expectWatchEvents(t, root, events, map[string]WatchOp{"new.txt": OpCreate})
+
+			writeTestFile(t, root, "existing.txt", "changed content")
+			expectWatchEvents(t, root, events, map[string]WatchOp{"existing.txt": OpModify})
+
+			// Files in a new directory are reported, excluded trees are not.
+			writeTestFile(t, root, "sub/deep/file.txt", "x")
+			writeTestFile(t, root, "ignored/file.txt", "x")
+			expectWatchEvents(t, root, events, map[string]WatchOp{"sub/deep/file.txt": OpCreate})
+
+			if err := os.Remove(filepath.Join(root, "new.txt")); err != nil {
+				t.Fatal(err)
+			}
+			expectWatchEvents(t, root, events, map[string]WatchOp{"new.txt": OpRemove})
+
+			// Create followed by remove within the debounce window cancels out.
+			writeTestFile(t, root, "temp.txt", "x")
+			if err := os.Remove(filepath.Join(root, "temp.txt")); err != nil {
+				t.Fatal(err)
+			}
+			expectNoWatchEvent(t, events, 300*time.Millisecond)
+		})
+	}
+}
+
+func TestWatchNativeRename(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rename events need inotify")
+	}
+	root := t.TempDir()
+	writeTestFile(t, root, "a.txt", "a")
+	events := startTestWatch(t, root, WatchOptions{})
+
+	if err := os.Rename(filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvents(t, root, events, map[string]WatchOp{"a.txt": OpRename, "b.txt": OpCreate})
+
+	// A directory moved into the tree is watched as well.
+	outside := t.TempDir()
+	writeTestFile(t, outside, "moved/inner.txt", "x")
+	if err := os.Rename(filepath.Join(outside, "moved"), filepath.Join(root, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchEvents(t, root, events, map[string]WatchOp{"moved/inner.txt": OpCreate})
+	writeTestFile(t, root, "moved/inner.txt", "changed")
+	expectWatchEvents(t, root, events, map[string]WatchOp{"moved/inner.txt": OpModify})
+}
+
+func TestMergeWatchOp(t *testing.T) {
+	tests := []struct {
+		old, cur WatchOp
+		want     WatchOp
+		keep     bool
+	}{
+		{OpCreate, OpModify, OpCreate, true},
+		{OpCreate, OpRemove, 0, false},
+		{OpModify, OpRemove, OpRemove, true},
+		{OpRemove, OpCreate, OpModify, true},
+		{OpRename, OpCreate, OpModify, true},
+		{OpModify, OpModify, OpModify, true},
+	}
+	for _, tt := range tests {
+		op, keep := mergeWatchOp(tt.old, tt.cur)
+		if op != tt.want || keep != tt.keep {
+			t.Errorf("mergeWatchOp(%s, %s) = %s, %v; want %s, %v", tt.old, tt.cur, op, keep, tt.want, tt.keep)
+		}
+	}
+}
+
+func TestDebounceWatchMaxDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	raw := make(chan WatchEvent, 1)
+	out := make(chan WatchEvent)
+	go debounceWatch(ctx, raw, out, 50*time.Millisecond, 150*time.Millisecond)
+
+	// Changes arrive faster than the debounce interval for much longer than
+	// the maximum delay; they must still be reported while it goes on.
+	stop := time.After(time.Second)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-out:
+			if e.Path != "busy" || e.Op != OpModify {
+				t.Errorf("got %s %s; want modify busy", e.Op, e.Path)
+			}
+			return
+		case <-ticker.C:
+			// Never block here: the debouncer may be waiting to deliver.
+			select {
+			case raw <- WatchEvent{Path: "busy", Op: OpModify}:
+			default:
+			}
+		case <-stop:
+			t.Fatal("continuous changes were never reported")
+		}
+	}
+}
+
+func TestDebounceWatchFlushesOnClose(t *testing.T) {
+	raw := make(chan WatchEvent, 2)
+	out := make(chan WatchEvent)
+	raw <- WatchEvent{Path: "b", Op: OpCreate}
+	raw <- WatchEvent{Path: "a", Op: OpRemove}
+	close(raw)
+	go debounceWatch(context.Background(), raw, out, time.Hour, time.Hour)
+
+	var got []WatchEvent
+	for e := range out {
+		got = append(got, e)
+	}
+	want := []WatchEvent{{Path: "a", Op: OpRemove}, {Path: "b", Op: OpCreate}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events after close = %v; want %v", got, want)
+	}
+}
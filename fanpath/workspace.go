@@ -0,0 +1,154 @@
+package fanpath
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Workspace 临时工作目录，用于测试和构建步骤，Close时整个删除
+type Workspace struct {
+	root string
+	once sync.Once
+	err  error
+}
+
+// NewWorkspace 在系统临时目录下创建名称以prefix开头的工作目录
+func NewWorkspace(prefix string) (*Workspace, error) {
+	root, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return nil, err
+	}
+	// macOS等平台的临时目录本身经过符号链接，解析后路径与遍历结果一致
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	return &Workspace{root: root}, nil
+}
+
+// TB testing.TB中NewTestWorkspace用到的方法，避免非测试代码依赖testing包
+type TB interface {
+	Helper()
+	Name() string
+	Cleanup(func())
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// NewTestWorkspace 为测试创建工作目录，测试结束时通过tb.Cleanup自动删除，创建失败时终止测试
+func NewTestWorkspace(tb TB) *Workspace {
+	tb.Helper()
+	prefix := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' {
+			return '_'
+		}
+		return r
+	}, tb.Name())
+	ws, err := NewWorkspace(prefix + "-")
+	if err != nil {
+		tb.Fatalf("NewWorkspace failed: %v", err)
+		return nil
+	}
+	tb.Cleanup(func() {
+		if err := ws.Close(); err != nil {
+			tb.Errorf("failed to remove workspace %s: %v", ws.Path(), err)
+		}
+	})
+	return ws
+}
+
+// Path 工作目录的绝对路径
+func (w *Workspace) Path() string {
+	return w.root
+}
+
+// Join 返回工作目录下的路径，rel可以使用/分隔
+func (w *Workspace) Join(rel ...string) string {
+	elems := make([]string, 0, len(rel)+1)
+	elems = append(elems, w.root)
+	for _, r := range rel {
+		elems = append(elems, filepath.FromSlash(r))
+	}
+	return filepath.Join(elems...)
+}
+
+// resolve 返回rel在工作目录下的路径，拒绝指向工作目录之外的路径
+func (w *Workspace) resolve(rel string) (string, error) {
+	p := w.Join(rel)
+	if !isWithin(w.root, p) {
+		return "", fmt.Errorf("%s is outside workspace %s", rel, w.root)
+	}
+	return p, nil
+}
+
+// WriteFile 写入工作目录下的文件，自动创建上级目录，返回文件路径
+func (w *Workspace) WriteFile(rel string, data []byte) (string, error) {
+	p, err := w.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return "", err
+	}
+	return p, os.WriteFile(p, data, 0644)
+}
+
+// MkdirAll 创建工作目录下的目录，返回目录路径
+func (w *Workspace) MkdirAll(rel string) (string, error) {
+	p, err := w.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	return p, os.MkdirAll(p, os.ModePerm)
+}
+
+// Snapshot 返回工作目录树的文本清单，便于与golden文件比较
+// 每行一项，按路径排序：目录以/结尾，文件为"路径 大小 内容sha256前12位"，符号链接为"路径 -> 目标"
+func (w *Workspace) Snapshot() (string, error) {
+	var sb strings.Builder
+	err := filepath.WalkDir(w.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.IsDir():
+			fmt.Fprintf(&sb, "%s/\n", rel)
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&sb, "%s -> %s\n", rel, filepath.ToSlash(target))
+		default:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			sum, err := hashFileBytes(p, HashSHA256)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&sb, "%s %d %s\n", rel, info.Size(), hex.EncodeToString(sum)[:12])
+		}
+		return nil
+	})
+	return sb.String(), err
+}
+
+// Close 删除工作目录，重复调用返回第一次的结果
+func (w *Workspace) Close() error {
+	w.once.Do(func() {
+		w.err = os.RemoveAll(w.root)
+	})
+	return w.err
+}
@@ -0,0 +1,79 @@
+package fanpath
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+// testing.TB must satisfy TB so NewTestWorkspace accepts *testing.T and *testing.B.
+var _ TB = testing.TB(nil)
+
+func TestWorkspace(t *testing.T) {
+	ws, err := NewWorkspace("fanpath-ws-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ws.WriteFile("b/c.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.WriteFile("a.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.MkdirAll("empty/sub"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.WriteFile("../escape.txt", []byte("x")); err == nil {
+		t.Error("WriteFile outside the workspace should fail")
+	}
+	if !ExistFile(ws.Join("b", "c.txt")) || !ExistFile(ws.Join("b/c.txt")) {
+		t.Error("Join did not resolve b/c.txt")
+	}
+
+	got, err := ws.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.txt 0 e3b0c44298fc\n" +
+		"b/\n" +
+		"b/c.txt 5 2cf24dba5fb0\n" +
+		"empty/\n" +
+		"empty/sub/\n"
+	if got != want {
+		t.Errorf("Snapshot() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := ws.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ExistPath(ws.Path()) {
+		t.Error("Close did not remove the workspace")
+	}
+	if err := ws.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+func TestNewTestWorkspace(t *testing.T) {
+	var path string
+	t.Run("sub/test", func(t *testing.T) {
+		ws := NewTestWorkspace(t)
+		path = ws.Path()
+		if _, err := ws.WriteFile("x", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" {
+			if err := os.Symlink("x", ws.Join("link")); err != nil {
+				t.Fatal(err)
+			}
+			snap, _ := ws.Snapshot()
+			if want := "link -> x\nx 1 2d711642b726\n"; snap != want {
+				t.Errorf("Snapshot() = %q; want %q", snap, want)
+			}
+		}
+	})
+	if path == "" || ExistPath(path) {
+		t.Errorf("workspace %q was not cleaned up", path)
+	}
+}